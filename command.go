@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/api"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// requirement describes what a command needs before it runs
+type requirement int
+
+const (
+	// needsNothing commands run without any configuration
+	needsNothing requirement = iota
+	// needsConfig commands load the configuration without validating it
+	needsConfig
+	// needsServer commands need a server but no password
+	needsServer
+	// needsCredentials commands need a server and validated credentials
+	needsCredentials
+	// needsLogin commands also authenticate before running
+	needsLogin
+)
+
+// errUsage is returned when a command is given the wrong arguments
+var errUsage = errors.New("invalid arguments")
+
+// The argument struct documents a positional argument of a command
+type argument struct {
+	Name        string
+	Description string
+	Optional    bool
+}
+
+// The command struct describes a subcommand, its flags and how it runs.
+//
+// The pre-run hook loads the configuration, creates the client and
+// authenticates according to Needs before Run is called with the positional
+// arguments.
+type command struct {
+	Name     string
+	Summary  string
+	Args     []argument
+	Examples []string
+	Needs    requirement
+
+	// Streaming commands transfer files of any size and run without a time
+	// limit unless their own timeouts entry sets one
+	Streaming bool
+
+	// Flags registers the typed flags of the command
+	Flags func(fs *flag.FlagSet)
+
+	// Run performs the command. The client is nil for commands that do not
+	// need a server.
+	Run func(ctx context.Context, client *api.Client, args []string) error
+}
+
+// synopsis returns the usage line of the command
+func (c *command) synopsis() string {
+	parts := []string{"ohaclient", c.Name}
+	if c.Flags != nil {
+		parts = append(parts, "[FLAGS]")
+	}
+	for _, arg := range c.Args {
+		if arg.Optional {
+			parts = append(parts, fmt.Sprintf("[%s]", arg.Name))
+		} else {
+			parts = append(parts, arg.Name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// requiredArgs returns the number of positional arguments that must be given
+func (c *command) requiredArgs() int {
+	n := 0
+	for _, arg := range c.Args {
+		if !arg.Optional {
+			n++
+		}
+	}
+	return n
+}
+
+// flagSet returns a new flag set holding the command's flags
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if c.Flags != nil {
+		c.Flags(fs)
+	}
+	return fs
+}
+
+// printHelp prints the help page of the command
+func (c *command) printHelp(w io.Writer) {
+	fmt.Fprintln(w, config.PrintColor("[+] Usage:", "yellow", "%s"))
+	fmt.Fprintln(w, c.synopsis())
+	fmt.Fprintln(w, config.PrintColor("[+] Description:", "yellow", "%s"))
+	fmt.Fprintln(w, c.Summary)
+
+	if len(c.Args) > 0 {
+		fmt.Fprintln(w, config.PrintColor("[+] Arguments:", "yellow", "%s"))
+		for _, arg := range c.Args {
+			fmt.Fprintln(w, config.PrintColor(arg.Name+":", "cyan", "%s"), arg.Description)
+		}
+	}
+
+	if c.Flags != nil {
+		fmt.Fprintln(w, config.PrintColor("[+] Flags:", "yellow", "%s"))
+		c.flagSet().VisitAll(func(f *flag.Flag) {
+			name, usage := flag.UnquoteUsage(f)
+			label := "--" + f.Name
+			if name != "" {
+				label += " " + name
+			}
+			if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+				usage = fmt.Sprintf("%s (default %s)", usage, f.DefValue)
+			}
+			fmt.Fprintln(w, config.PrintColor(label+":", "cyan", "%s"), usage)
+		})
+	}
+
+	if len(c.Examples) > 0 {
+		fmt.Fprintln(w, config.PrintColor("[+] Examples:", "yellow", "%s"))
+		for _, example := range c.Examples {
+			fmt.Fprintln(w, example)
+		}
+	}
+
+	fmt.Fprintln(w, config.PrintColor("[+] Global Flags:", "yellow", "%s"))
+	fmt.Fprintln(w, "Run ohaclient help to list the global flags.")
+}
+
+// parseArgs parses the command's flags, which may appear before, between or
+// after positional arguments, and returns the positional arguments.
+//
+// Arguments following "--" are never parsed as flags.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		consumed := len(args) - len(rest)
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// byteSizeFlag is a flag holding a size in bytes such as 8MB or 512KiB
+type byteSizeFlag int64
+
+// String implements flag.Value
+func (b *byteSizeFlag) String() string {
+	return models.FormatByteSize(int64(*b))
+}
+
+// Set implements flag.Value
+func (b *byteSizeFlag) Set(value string) error {
+	size, err := models.ParseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSizeFlag(size)
+	return nil
+}
+
+// findCommand returns the command called name
+func findCommand(name string) (*command, bool) {
+	for _, c := range commands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// runCommand parses the arguments of c, runs the pre-run hook and then the
+// command itself.
+//
+// Help requests print the command's help page and exit successfully. Usage
+// errors print the help page to stderr.
+func runCommand(c *command, args []string) {
+	fs := c.flagSet()
+	positional, err := parseArgs(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		c.printHelp(os.Stdout)
+		os.Exit(0)
+	}
+	if err == nil && (len(positional) < c.requiredArgs() || len(positional) > len(c.Args)) {
+		err = fmt.Errorf("%w: expected %s", errUsage, c.synopsis())
+	}
+	if err != nil {
+		if !errors.Is(err, errUsage) {
+			err = fmt.Errorf("%w: %s", errUsage, err)
+		}
+		c.printHelp(os.Stderr)
+		checkError(err)
+	}
+
+	ctx, client, cleanup := preRun(c)
+	defer cleanup()
+
+	err = c.Run(ctx, client, positional)
+	if errors.Is(err, errUsage) {
+		c.printHelp(os.Stderr)
+	}
+	checkError(err)
+	checkError(output.Flush())
+}
+
+// preRun loads and validates the configuration, creates the client, applies
+// the operation timeout and authenticates, as far as the command needs.
+//
+// The function returns the context for the command, the client, which is
+// nil when no server is needed, and a function releasing the context.
+func preRun(c *command) (context.Context, *api.Client, func()) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if c.Needs == needsNothing {
+		return ctx, nil, stop
+	}
+
+	checkError(loadConfig())
+	switch c.Needs {
+	case needsConfig:
+		return ctx, nil, stop
+	case needsServer:
+		requireServer()
+	case needsLogin:
+		// The password is resolved by Authenticate when no cached token
+		// is usable
+		requireServer()
+		checkError(models.ValidateUsername(configFile.ClientUsername))
+	default:
+		requireCredentials()
+	}
+
+	client, err := api.NewClient(OHAServerURL, configFile)
+	checkError(err)
+	client.Debug = debugFlag
+
+	timeout, err := configFile.OperationTimeout(c.Name, api.DefaultTimeout)
+	if _, ok := configFile.Timeouts[c.Name]; c.Streaming && !ok {
+		// The default entry is sized for quick commands and would cut off
+		// large transfers, so streaming commands only use their own entry
+		timeout, err = 0, nil
+	}
+	checkError(err)
+	if timeoutFlag != "" {
+		timeout, err = time.ParseDuration(timeoutFlag)
+		if err != nil || timeout < 0 {
+			checkError(fmt.Errorf("invalid --timeout: %s", timeoutFlag))
+		}
+	}
+
+	client.Timeout = timeout
+	cleanup := stop
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		cleanup = func() {
+			cancel()
+			stop()
+		}
+	}
+
+	if c.Needs == needsLogin {
+		err = client.Authenticate(ctx, configFile.ClientUsername, resolvePassword)
+		checkError(err)
+	}
+
+	return ctx, client, cleanup
+}
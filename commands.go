@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/api"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// commands lists every subcommand in the order shown by help
+var commands []*command
+
+func init() {
+	commands = []*command{
+		helpCommand(),
+		versionCommand(),
+		completionCommand(),
+		configCommand(),
+		{
+			Name:     "register",
+			Summary:  "Registers a user on the OHA Server, prompting for missing credentials.",
+			Examples: []string{"ohaclient register"},
+			Needs:    needsServer,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				return registerUser(ctx, client)
+			},
+		},
+		{
+			Name:     "login",
+			Summary:  "Authenticates with the OHA Server and caches the token.",
+			Examples: []string{"ohaclient login"},
+			Needs:    needsCredentials,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				token, err := client.ServerAuthenticate(ctx, configFile.ClientUsername, configFile.ClientPassword)
+				if err != nil {
+					return err
+				}
+
+				expires, err := api.SaveCachedToken(OHAServerURL, configFile.ClientUsername, token)
+				if err != nil {
+					return err
+				}
+				fmt.Println(config.PrintColor(fmt.Sprintf("[+] Logged in as %s. Token cached until %s", configFile.ClientUsername, expires.Format(time.RFC3339)), "green", "%s"))
+				return nil
+			},
+		},
+		{
+			Name:     "logout",
+			Summary:  "Removes the cached token.",
+			Examples: []string{"ohaclient logout"},
+			Needs:    needsServer,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				if err := api.ClearCachedToken(OHAServerURL, configFile.ClientUsername); err != nil {
+					return err
+				}
+				fmt.Println(config.PrintColor(fmt.Sprintf("[+] Cached token for %s removed", configFile.ClientUsername), "green", "%s"))
+				return nil
+			},
+		},
+		{
+			Name:     "manage",
+			Summary:  "Changes user permissions for target user.",
+			Args:     []argument{{Name: "UID", Description: "ID of the user whose permissions are changed."}},
+			Examples: []string{"ohaclient manage 2"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				uid, err := models.ValidateIntInputArgs(args, 0)
+				if err != nil {
+					return err
+				}
+				return client.ManageUser(ctx, uid)
+			},
+		},
+		{
+			Name:    "search",
+			Summary: "Searches the OHA Server for any matching HASH values in a file.",
+			Args: []argument{
+				{Name: "FILE", Description: "File with one HASH per line."},
+				{Name: "QUERY-STRING", Description: "Filter passed to the server as the query string.", Optional: true},
+			},
+			Examples: []string{"ohaclient search hashes.txt"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				filepath, err := models.ValidateFileInputArgs(args, 0)
+				if err != nil {
+					return err
+				}
+
+				query, err := models.ValidateQueryStringArgs(args, 1)
+				if err != nil {
+					return err
+				}
+				return client.SearchFounds(ctx, filepath, query)
+			},
+		},
+		submitCommand(),
+		{
+			Name:     "health",
+			Summary:  "Requests the OHA Server settings then prints them.",
+			Examples: []string{"ohaclient health"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				return client.HealthCheck(ctx)
+			},
+		},
+		{
+			Name:     "status",
+			Summary:  "Check the status of downloadable files on the OHA Server.",
+			Examples: []string{"ohaclient status"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				return client.StatusCheck(ctx)
+			},
+		},
+		downloadCommand("wordlist"),
+		downloadCommand("rules"),
+		downloadCommand("masks"),
+		{
+			Name:     "lists",
+			Summary:  "View or downloads the available lists on the OHA Server.",
+			Args:     []argument{{Name: "LISTNAME", Description: "List to download. All lists are shown when omitted.", Optional: true}},
+			Examples: []string{"ohaclient lists", "ohaclient lists LISTNAME"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				if len(args) == 0 {
+					return client.ListAllPrivateLists(ctx)
+				}
+
+				listname, err := models.ValidateQueryStringArgs(args, 0)
+				if err != nil {
+					return err
+				}
+				return client.ListTargetPrivateList(ctx, listname)
+			},
+		},
+		{
+			Name:    "create",
+			Summary: "Create a new private list on the OHA Server.",
+			Args: []argument{
+				{Name: "LISTNAME", Description: "Name of the new list."},
+				{Name: "FILE", Description: "File with the contents of the list."},
+			},
+			Examples: []string{"ohaclient create LISTNAME FILE"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				listname, err := models.ValidateQueryStringArgs(args, 0)
+				if err != nil {
+					return err
+				}
+
+				infile, err := models.ValidateQueryStringArgs(args, 1)
+				if err != nil {
+					return err
+				}
+				return client.CreateNewPrivateList(ctx, infile, listname)
+			},
+		},
+		{
+			Name:    "update",
+			Summary: "Updates the target list on the OHA Server.",
+			Args: []argument{
+				{Name: "LISTNAME", Description: "Name of the list to update."},
+				{Name: "FILE", Description: "File with the new contents of the list."},
+			},
+			Examples: []string{"ohaclient update LISTNAME FILE"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				listname, err := models.ValidateQueryStringArgs(args, 0)
+				if err != nil {
+					return err
+				}
+
+				infile, err := models.ValidateQueryStringArgs(args, 1)
+				if err != nil {
+					return err
+				}
+				return client.UpdateTargetPrivateList(ctx, listname, infile)
+			},
+		},
+		{
+			Name:     "refresh",
+			Summary:  "Refreshes the target generated file on the OHA Server.",
+			Args:     []argument{{Name: "FILE", Description: "Generated file to rebuild: Masks, Rules or Wordlist."}},
+			Examples: []string{"ohaclient refresh Wordlist"},
+			Needs:    needsLogin,
+			Run: func(ctx context.Context, client *api.Client, args []string) error {
+				file, err := models.ValidateQueryStringArgs(args, 0)
+				if err != nil {
+					return err
+				}
+				return client.RefreshGeneratedFile(ctx, file)
+			},
+		},
+	}
+}
+
+// helpCommand returns the help command, which lists every command or shows
+// the help page of one
+func helpCommand() *command {
+	return &command{
+		Name:     "help",
+		Summary:  "Prints the available commands or the help page of COMMAND.",
+		Args:     []argument{{Name: "COMMAND", Description: "Command to describe.", Optional: true}},
+		Examples: []string{"ohaclient help", "ohaclient help submit"},
+		Run: func(ctx context.Context, client *api.Client, args []string) error {
+			if len(args) == 1 {
+				c, ok := findCommand(args[0])
+				if !ok {
+					return fmt.Errorf("%w: unknown command: %s", errUsage, args[0])
+				}
+				c.printHelp(os.Stdout)
+				return nil
+			}
+
+			// Show the configuration in the header when there is one
+			if loadConfig() == nil {
+				OHAServerURL, _ = configFile.ServerBaseURL()
+			}
+			printUsage()
+			return nil
+		},
+	}
+}
+
+// versionCommand returns the version command
+func versionCommand() *command {
+	return &command{
+		Name:     "version",
+		Summary:  "Prints the client version.",
+		Examples: []string{"ohaclient version"},
+		Run: func(ctx context.Context, client *api.Client, args []string) error {
+			printVersion()
+			return nil
+		},
+	}
+}
+
+// completionCommand returns the completion command
+func completionCommand() *command {
+	return &command{
+		Name:     "completion",
+		Summary:  "Prints a bash, zsh or fish completion script.",
+		Args:     []argument{{Name: "SHELL", Description: "One of bash, zsh or fish."}},
+		Examples: []string{"source <(ohaclient completion bash)"},
+		Run: func(ctx context.Context, client *api.Client, args []string) error {
+			return runCompletion(args)
+		},
+	}
+}
+
+// configCommand returns the config command
+func configCommand() *command {
+	var showOrigin bool
+	return &command{
+		Name:    "config",
+		Summary: "Creates, shows, validates or edits the client configuration.",
+		Args: []argument{
+			{Name: "ACTION", Description: "One of init, show, validate, set or fix-perms."},
+			{Name: "KEY", Description: "Configuration key for set, e.g. server or timeouts.submit.", Optional: true},
+			{Name: "VALUE", Description: "Value for set.", Optional: true},
+		},
+		Examples: []string{
+			"ohaclient config init",
+			"ohaclient config show --origin",
+			"ohaclient config set timeouts.submit 2h",
+		},
+		Needs: needsConfig,
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&showOrigin, "origin", false, "Shows where each value of config show came from.")
+		},
+		Run: func(ctx context.Context, client *api.Client, args []string) error {
+			return runConfig(ctx, args, showOrigin)
+		},
+	}
+}
+
+// submitCommand returns the submit command
+func submitCommand() *command {
+	opts := api.SubmitOptions{
+		BatchLines:  api.DefaultBatchLines,
+		BatchBytes:  api.DefaultBatchBytes,
+		Workers:     api.DefaultWorkers,
+		MaxInFlight: api.DefaultMaxInFlight,
+		Validate:    true,
+	}
+	return &command{
+		Name:    "submit",
+		Summary: "Submit a file containing HASH:PLAIN values to the OHA Server in batches.",
+		Args: []argument{
+			{Name: "ALGO", Description: "Hashcat mode of the hashes, e.g. 0 for MD5."},
+			{Name: "FILE", Description: "File with one HASH:PLAIN per line. It is streamed, so it may be larger than memory."},
+		},
+		Examples: []string{
+			"ohaclient submit 0 founds.txt",
+			"ohaclient submit --batch-lines 10000 --batch-bytes 2MiB 1000 hashcat.potfile",
+			"ohaclient submit --workers 4 --max-in-flight 32MiB 0 founds.txt",
+			"ohaclient submit --resume 0 founds.txt",
+			"ohaclient submit --validate=false 0 founds.txt",
+			"ohaclient submit --verify 1000 founds.txt",
+		},
+		Needs:     needsLogin,
+		Streaming: true,
+		Flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&opts.BatchLines, "batch-lines", opts.BatchLines, "Sends at most `N` lines per request.")
+			fs.Var((*byteSizeFlag)(&opts.BatchBytes), "batch-bytes", "Sends at most `SIZE` of encoded lines per request, e.g. 512KiB or 8MB.")
+			fs.IntVar(&opts.Workers, "workers", opts.Workers, fmt.Sprintf("Sends up to `N` batches at once, at most %d.", api.MaxWorkers))
+			fs.Var((*byteSizeFlag)(&opts.MaxInFlight), "max-in-flight", "Limits the batches being sent at once to `SIZE` in total.")
+			fs.BoolVar(&opts.Resume, "resume", false, "Continues an interrupted submission of FILE from its journal.")
+			fs.BoolVar(&opts.Validate, "validate", true, "Checks each line against ALGO before sending it and writes rejects to FILE.rejected.")
+			fs.BoolVar(&opts.Verify, "verify", false, "Recomputes the hash of each plaintext and drops the lines that do not match.")
+		},
+		Run: func(ctx context.Context, client *api.Client, args []string) error {
+			algo, err := models.ValidateIntInputArgs(args, 0)
+			if err != nil {
+				return err
+			}
+
+			filepath, err := models.ValidateFileInputArgs(args, 1)
+			if err != nil {
+				return err
+			}
+
+			if opts.BatchLines <= 0 || opts.BatchBytes <= 0 || opts.MaxInFlight <= 0 {
+				return fmt.Errorf("%w: --batch-lines, --batch-bytes and --max-in-flight must be greater than 0", errUsage)
+			}
+			if opts.Workers < 1 || opts.Workers > api.MaxWorkers {
+				return fmt.Errorf("%w: --workers must be between 1 and %d", errUsage, api.MaxWorkers)
+			}
+			if opts.Verify && !opts.Validate {
+				return fmt.Errorf("%w: --verify cannot be used with --validate=false", errUsage)
+			}
+			if opts.Verify && !models.CanVerify(algo) {
+				fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[!] Mode %s cannot be verified locally. Its lines are sent unverified", algo), "yellow", "%s"))
+			}
+			if _, ok := models.HashLengths[algo]; opts.Validate && !ok {
+				fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[!] Mode %s has no known hash length. Only the HASH:PLAIN format is checked", algo), "yellow", "%s"))
+			}
+			return client.SubmitFounds(ctx, algo, filepath, opts)
+		},
+	}
+}
+
+// downloadCommand returns a command downloading the named generated file
+func downloadCommand(name string) *command {
+	var outputFile string
+	return &command{
+		Name:    name,
+		Summary: fmt.Sprintf("Downloads portions of the %s file from the OHA Server.", name),
+		Args: []argument{
+			{Name: "NUM", Description: "Number of lines to download."},
+			{Name: "QUERY-STRING", Description: "Filter passed to the server as the query string.", Optional: true},
+		},
+		Examples: []string{
+			fmt.Sprintf("ohaclient %s 1000", name),
+			fmt.Sprintf("ohaclient %s --output %s.txt 1000", name, name),
+		},
+		Needs:     needsLogin,
+		Streaming: true,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&outputFile, "output", "", "Writes the download to `FILE` instead of stdout.")
+		},
+		Run: func(ctx context.Context, client *api.Client, args []string) error {
+			num, err := models.ValidateIntInputArgs(args, 0)
+			if err != nil {
+				return err
+			}
+
+			query, err := models.ValidateQueryStringArgs(args, 1)
+			if err != nil {
+				return err
+			}
+
+			if outputFile == "" {
+				return client.DownloadResource(ctx, output, name, num, query)
+			}
+			return downloadToFile(ctx, client, outputFile, name, num, query)
+		},
+	}
+}
+
+// downloadToFile downloads the named generated file to path.
+//
+// Data received before a failure is kept in the file. The function returns
+// any error that occurred.
+func downloadToFile(ctx context.Context, client *api.Client, path string, name string, num string, query string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	err = client.DownloadResource(ctx, w, name, num, query)
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
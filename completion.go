@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// configCommands lists the config subcommands
+var configCommands = []string{"init", "show", "validate", "set", "fix-perms"}
+
+// fileCommands take a file argument
+var fileCommands = []string{"search", "submit", "create", "update"}
+
+// completionShells lists the shells runCompletion supports
+var completionShells = []string{"bash", "zsh", "fish"}
+
+const bashCompletion = `# ohaclient bash completion
+_ohaclient() {
+    local cur prev word command
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+        --config|--ca-file|--client-cert|--client-key|--password-file)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            return
+            ;;
+    esac
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+
+    for word in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do
+        if [[ "$word" != -* ]]; then
+            command="$word"
+            break
+        fi
+    done
+
+    case "$command" in
+        "")
+            COMPREPLY=($(compgen -W "%s" -- "$cur"))
+            ;;
+        config)
+            COMPREPLY=($(compgen -W "%s" -- "$cur"))
+            ;;
+        completion)
+            COMPREPLY=($(compgen -W "%s" -- "$cur"))
+            ;;
+        refresh)
+            COMPREPLY=($(compgen -W "Masks Rules Wordlist" -- "$cur"))
+            ;;
+        %s)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            ;;
+    esac
+}
+complete -F _ohaclient ohaclient
+`
+
+const zshCompletion = `# ohaclient zsh completion
+autoload -U +X bashcompinit && bashcompinit
+`
+
+// completionFlags returns every global flag in sorted order
+func completionFlags() []string {
+	flags := []string{"--config", "--debug", "--help", "--profile", "--timeout", "--version", "-v"}
+	for _, f := range models.ConfigFields {
+		if f.Flag {
+			flags = append(flags, "--"+f.Key)
+		}
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// completionCommands returns every command in sorted order
+func completionCommands() []string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bashCompletionScript returns the bash completion script
+func bashCompletionScript() string {
+	return fmt.Sprintf(bashCompletion,
+		strings.Join(completionFlags(), " "),
+		strings.Join(completionCommands(), " "),
+		strings.Join(configCommands, " "),
+		strings.Join(completionShells, " "),
+		strings.Join(fileCommands, "|"),
+	)
+}
+
+// fishCompletionScript returns the fish completion script
+func fishCompletionScript() string {
+	var b strings.Builder
+	b.WriteString("# ohaclient fish completion\n")
+	b.WriteString("complete -c ohaclient -f\n")
+	fmt.Fprintf(&b, "complete -c ohaclient -n __fish_use_subcommand -a %q\n", strings.Join(completionCommands(), " "))
+	fmt.Fprintf(&b, "complete -c ohaclient -n '__fish_seen_subcommand_from config' -a %q\n", strings.Join(configCommands, " "))
+	fmt.Fprintf(&b, "complete -c ohaclient -n '__fish_seen_subcommand_from completion' -a %q\n", strings.Join(completionShells, " "))
+	b.WriteString("complete -c ohaclient -n '__fish_seen_subcommand_from refresh' -a 'Masks Rules Wordlist'\n")
+	fmt.Fprintf(&b, "complete -c ohaclient -n '__fish_seen_subcommand_from %s' -F\n", strings.Join(fileCommands, " "))
+	for _, flag := range completionFlags() {
+		if name, ok := strings.CutPrefix(flag, "--"); ok {
+			fmt.Fprintf(&b, "complete -c ohaclient -l %s\n", name)
+		} else {
+			fmt.Fprintf(&b, "complete -c ohaclient -s %s\n", strings.TrimPrefix(flag, "-"))
+		}
+	}
+	return b.String()
+}
+
+// runCompletion prints the completion script for the shell named in args.
+//
+// The function returns an error when the shell is missing or unsupported.
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ohaclient completion [%s]", strings.Join(completionShells, "|"))
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletionScript())
+	case "zsh":
+		fmt.Print(zshCompletion + bashCompletionScript())
+	case "fish":
+		fmt.Print(fishCompletionScript())
+	default:
+		return fmt.Errorf("unsupported shell: %s. Expected one of: %s", args[0], strings.Join(completionShells, ", "))
+	}
+	return nil
+}
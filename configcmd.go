@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/api"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// configTestTimeout limits the connectivity checks run by config init and
+// config validate
+const configTestTimeout = 30 * time.Second
+
+// runConfig handles the config subcommands. The connectivity checks run
+// within ctx.
+//
+// The function returns errUsage when the arguments do not match an action.
+func runConfig(ctx context.Context, args []string, showOrigin bool) error {
+	switch {
+	case args[0] == "init" && len(args) == 1:
+		return configInit(ctx, configPath)
+	case args[0] == "show" && len(args) == 1:
+		printConfig(configFile, showOrigin)
+	case args[0] == "validate" && len(args) == 1:
+		return configValidate(ctx, configFile)
+	case args[0] == "set" && len(args) == 3:
+		if err := models.SetConfigValue(configPath, activeProfile(), args[1], args[2]); err != nil {
+			return err
+		}
+		location := configPath
+		if profile := activeProfile(); profile != "" {
+			location = fmt.Sprintf("%s (profile %s)", configPath, profile)
+		}
+		fmt.Println(config.PrintColor(fmt.Sprintf("[+] Set %s in %s", args[1], location), "green", "%s"))
+	case args[0] == "fix-perms" && len(args) == 1:
+		if err := models.FixConfigPermissions(configPath); err != nil {
+			return err
+		}
+		fmt.Println(config.PrintColor(fmt.Sprintf("[+] Restricted %s to mode 0600", configPath), "green", "%s"))
+	default:
+		return fmt.Errorf("%w: unknown config action: %s", errUsage, strings.Join(args, " "))
+	}
+	return nil
+}
+
+// configInit interactively creates the configuration file at path, testing
+// the server and credentials before it is written
+func configInit(ctx context.Context, path string) error {
+	if !config.IsTerminal() {
+		return errors.New("config init must be run from an interactive terminal")
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if !confirm(fmt.Sprintf("%s already exists. Overwrite it? (y/n): ", path)) {
+			return nil
+		}
+	}
+
+	var conf models.Configuration
+	var serverURL string
+	for {
+		input, err := config.Prompt("OHA Server URL (e.g. https://oha.example.com:8080/api): ")
+		if err != nil {
+			return err
+		}
+		serverURL, err = models.ParseServerURL(input)
+		if err == nil {
+			conf.Server = input
+			break
+		}
+		fmt.Println(config.PrintColor(fmt.Sprintf("[!] %s", err), "red", "%s"))
+	}
+
+	for {
+		input, err := config.Prompt("Username: ")
+		if err != nil {
+			return err
+		}
+		err = models.ValidateUsername(input)
+		if err == nil {
+			conf.ClientUsername = input
+			break
+		}
+		fmt.Println(config.PrintColor(fmt.Sprintf("[!] %s", err), "red", "%s"))
+	}
+
+	password, err := config.PromptPassword("Password (leave blank to be prompted on each run): ")
+	if err != nil {
+		return err
+	}
+	conf.ClientPassword = password
+
+	conf.CAFile, err = config.Prompt("Private CA file (optional): ")
+	if err != nil {
+		return err
+	}
+
+	if err := testConnection(ctx, serverURL, conf); err != nil {
+		fmt.Println(config.PrintColor(fmt.Sprintf("[!] Connection test failed: %s", err), "red", "%s"))
+		if !confirm("Save the configuration anyway? (y/n): ") {
+			return nil
+		}
+	} else {
+		fmt.Println(config.PrintColor("[+] Connected to the OHA Server", "green", "%s"))
+	}
+
+	values := map[string]interface{}{
+		"server":          conf.Server,
+		"client-username": conf.ClientUsername,
+	}
+	if conf.ClientPassword != "" {
+		values["client-password"] = conf.ClientPassword
+	}
+	if conf.CAFile != "" {
+		values["ca-file"] = conf.CAFile
+	}
+
+	if err := models.WriteConfigFile(path, values); err != nil {
+		return err
+	}
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Configuration written to %s", path), "green", "%s"))
+	return nil
+}
+
+// testConnection checks that the server is reachable and, when a password is
+// set, that the credentials are accepted
+func testConnection(ctx context.Context, serverURL string, conf models.Configuration) error {
+	ctx, cancel := context.WithTimeout(ctx, configTestTimeout)
+	defer cancel()
+
+	client, err := api.NewClient(serverURL, conf)
+	if err != nil {
+		return err
+	}
+	client.Debug = debugFlag
+
+	if conf.ClientPassword == "" {
+		_, err := client.GetRequest(ctx, "/health")
+		if errors.Is(err, api.ErrUnauthorized) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = client.ServerAuthenticate(ctx, conf.ClientUsername, conf.ClientPassword)
+	return err
+}
+
+// configValidate checks the effective configuration locally and then logs in
+// and requests the server health
+func configValidate(ctx context.Context, conf models.Configuration) error {
+	if err := models.ResolvePassword(&conf); err != nil {
+		return err
+	}
+
+	if err := models.ValidateConfig(conf); err != nil {
+		return err
+	}
+
+	serverURL, err := conf.ServerBaseURL()
+	if err != nil {
+		return err
+	}
+	fmt.Println(config.PrintColor("[+] Configuration is valid", "green", "%s"))
+
+	ctx, cancel := context.WithTimeout(ctx, configTestTimeout)
+	defer cancel()
+
+	client, err := api.NewClient(serverURL, conf)
+	if err != nil {
+		return err
+	}
+	client.Debug = debugFlag
+
+	if _, err := client.ServerAuthenticate(ctx, conf.ClientUsername, conf.ClientPassword); err != nil {
+		return err
+	}
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Logged in to %s as %s", serverURL, conf.ClientUsername), "green", "%s"))
+
+	return client.HealthCheck(ctx)
+}
+
+// confirm asks a yes or no question and reports whether the answer was yes
+func confirm(question string) bool {
+	input, err := config.Prompt(question)
+	if err != nil {
+		return false
+	}
+	input = strings.TrimSpace(strings.ToLower(input))
+	return input == "y" || input == "yes"
+}
+
+// printConfig prints the effective configuration with secrets masked and,
+// when withOrigin is set, where each value came from
+func printConfig(conf models.Configuration, withOrigin bool) {
+	w := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	if conf.Profile != "" {
+		fmt.Fprintf(w, "profile\t%s\n", conf.Profile)
+	}
+
+	unused := map[string]bool{}
+	for _, key := range conf.UnusedServerKeys() {
+		unused[key] = true
+	}
+
+	for _, f := range models.ConfigFields {
+		value := f.Display(&conf)
+		if unused[f.Key] {
+			value += " (unused)"
+		}
+
+		if withOrigin {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Key, value, conf.Origin(f.Key))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", f.Key, value)
+		}
+	}
+
+	for _, op := range sortedKeys(conf.Timeouts) {
+		if withOrigin {
+			fmt.Fprintf(w, "timeouts.%s\t%s\t%s\n", op, conf.Timeouts[op], conf.Origin("timeouts"))
+		} else {
+			fmt.Fprintf(w, "timeouts.%s\t%s\n", op, conf.Timeouts[op])
+		}
+	}
+	w.Flush()
+
+	if serverURL, err := conf.ServerBaseURL(); err == nil {
+		fmt.Fprintf(output, "\nThe server URL in use is %s\n", serverURL)
+	}
+}
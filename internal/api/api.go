@@ -3,8 +3,7 @@ package api
 
 import (
 	"bufio"
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,113 +17,22 @@ import (
 	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
 )
 
-// PostRequest sends an HTTP POST request to the specified URL and route with the given data.
-//
-// If auth is true, an Authorization header is added to the request.
-//
-// The function returns the response body as a byte slice and any error that occurred.
-func PostRequest(url string, route string, data string, jwt string) ([]byte, error) {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
-	client := &http.Client{Transport: tr}
-
-	reqURL := fmt.Sprintf("%s%s", url, route)
-	req, err := http.NewRequest(http.MethodPost, reqURL, strings.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-
-	if jwt != "" {
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
-	}
-
-	req.Header.Add("Content-Type", "application/json")
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	resBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	return resBody, nil
-}
-
-// GetRequest sends an HTTP GET request to the specified URL and route.
-//
-// If a jwt is provided, an Authorization header is added to the request.
-//
-// The function returns the response body as a byte slice and any error that occurred.
-func GetRequest(url string, route string, jwt string) ([]byte, error) {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
-	client := &http.Client{Transport: tr}
-
-	reqURL := fmt.Sprintf("%s%s", url, route)
-	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if jwt != "" {
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
-	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	resBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	return resBody, nil
-}
-
-// ServerAuthenticate sends a POST request to the /api/login route of the specified URL
-// with the credentials stored in the environment variables.
+// ServerAuthenticate sends a POST request to the /api/login route of the client URL
+// with the provided credentials.
 //
+// On success the token is stored on the client for subsequent requests.
 // The function returns a valid JWT as a string and any error that occurred.
-func ServerAuthenticate(url string, username string, password string) (string, error) {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
-	client := &http.Client{Transport: tr}
-
+func (c *Client) ServerAuthenticate(ctx context.Context, username string, password string) (string, error) {
 	jsondata := &models.UserCredentials{Username: username, Password: password}
 	encjson, err := json.Marshal(jsondata)
 	if err != nil {
 		return "", err
 	}
 
-	reqURL := fmt.Sprintf("%s%s", url, "/login")
-	req, err := http.NewRequest(http.MethodPost, reqURL, bytes.NewBuffer(encjson))
-	if err != nil {
-		return "", err
+	resBody, err := c.postIdempotent(ctx, "/login", encjson)
+	if errors.Is(err, ErrUnauthorized) {
+		return "", fmt.Errorf("username or password is incorrect: %w", err)
 	}
-
-	req.Header.Add("Content-Type", "application/json")
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
-
-	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
 		return "", err
 	}
@@ -135,43 +43,49 @@ func ServerAuthenticate(url string, username string, password string) (string, e
 	}
 
 	if body["error"] != nil {
-		return "", errors.New("username or password is incorrect")
+		return "", fmt.Errorf("username or password is incorrect: %w", ErrUnauthorized)
 	}
-	return fmt.Sprintf("%v", body["token"]), nil
+
+	token := fmt.Sprintf("%v", body["token"])
+	c.setToken(token)
+	return token, nil
 }
 
-// RegisterUser sends a POST request to the /api/register route of the specified URL
-// with the credentials stored in the environment variables.
+// RegisterUser sends a POST request to the /api/register route of the client URL
+// with the provided credentials.
 //
-// The function prints the response body and returns any error that occurred.
-func RegisterUser(url string, username string, password string) error {
+// Registration is never retried so a duplicate account is not created. The
+// function returns the server message and any error that occurred.
+func (c *Client) RegisterUser(ctx context.Context, username string, password string) (string, error) {
 	jsondata := &models.UserCredentials{Username: username, Password: password}
 	encjson, err := json.Marshal(jsondata)
 	if err != nil {
-		return err
+		return "", err
 	}
-	res, err := PostRequest(url, "/register", string(encjson), "")
+	res, err := c.PostRequest(ctx, "/register", string(encjson))
 	if err != nil {
-		return err
+		return "", err
 	}
-	fmt.Println(string(res))
-	return nil
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(res, &body); err == nil && body["message"] != nil {
+		return fmt.Sprintf("%v", body["message"]), nil
+	}
+	return strings.TrimSpace(string(res)), nil
 }
 
-// ManageUser sends a POST request to the /api/manage/permissions route of the specified
+// ManageUser sends a POST request to the /api/manage/permissions route of the client
 // URL.
 //
 // The function prints the response body and returns any error that occured.
-func ManageUser(url string, jwt string, uid string) error {
+func (c *Client) ManageUser(ctx context.Context, uid string) error {
 	uidInt, err := strconv.Atoi(uid)
 	config.CheckError(err)
 
-	reader := bufio.NewReader(os.Stdin)
 	userPermissions := models.UserPermissions{UserID: uidInt}
 	permissions := []string{"CanLogin", "CanUpload", "CanSearch", "CanManage"}
 	for _, permission := range permissions {
-		fmt.Printf("Change permission for %s to true? (y/n): ", permission)
-		input, _ := reader.ReadString('\n')
+		input, _ := config.Prompt(fmt.Sprintf("Change permission for %s to true? (y/n): ", permission))
 		input = strings.TrimSpace(strings.ToLower(input))
 		if input == "y" || input == "yes" {
 			switch permission {
@@ -194,7 +108,7 @@ func ManageUser(url string, jwt string, uid string) error {
 		return err
 	}
 
-	res, err := PostRequest(url, "/manage/permissions", string(encjson), jwt)
+	res, err := c.PostRequest(ctx, "/manage/permissions", string(encjson))
 	if err != nil {
 		return err
 	}
@@ -202,11 +116,11 @@ func ManageUser(url string, jwt string, uid string) error {
 	return nil
 }
 
-// HealthCheck sends a GET request to the /api/health route of the specified URL.
+// HealthCheck sends a GET request to the /api/health route of the client URL.
 //
 // The function prints the response body and returns any error that occurred.
-func HealthCheck(url string, jwt string) error {
-	res, err := GetRequest(url, "/health", jwt)
+func (c *Client) HealthCheck(ctx context.Context) error {
+	res, err := c.GetRequest(ctx, "/health")
 	if err != nil {
 		return err
 	}
@@ -224,50 +138,11 @@ func HealthCheck(url string, jwt string) error {
 	return nil
 }
 
-// StatusCheck sends a GET request to the /api/status route of the specified URL.
+// StatusCheck sends a GET request to the /api/status route of the client URL.
 //
 // The function prints the response body and returns any error that occurred.
-func StatusCheck(url string, jwt string) error {
-	res, err := GetRequest(url, "/status", jwt)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(res))
-	return nil
-}
-
-// SubmitFounds sends a POST request to the /api/found route of the specified URL
-// with the hashes read from the specified file and the given algorithm.
-//
-// The function prints the response body and returns any error that occurred.
-func SubmitFounds(url string, jwt string, alg string, infile string) error {
-	buf, err := os.Open(infile)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err = buf.Close(); err != nil {
-			fmt.Printf("Error: %s\n", err)
-			os.Exit(0)
-		}
-	}()
-
-	filescanner := bufio.NewScanner(buf)
-	if err := filescanner.Err(); err != nil {
-		fmt.Printf("Error: %s\n", err)
-		os.Exit(0)
-	}
-	var fileHashes []string
-	for filescanner.Scan() {
-		fileHashes = append(fileHashes, filescanner.Text())
-	}
-	jsondata := &models.UploadHashes{Algorithm: fmt.Sprintf("%s", alg), HashPlain: fileHashes}
-	encjson, err := json.Marshal(jsondata)
-	if err != nil {
-		return err
-	}
-
-	res, err := PostRequest(url, "/found", string(encjson), jwt)
+func (c *Client) StatusCheck(ctx context.Context) error {
+	res, err := c.GetRequest(ctx, "/status")
 	if err != nil {
 		return err
 	}
@@ -275,11 +150,11 @@ func SubmitFounds(url string, jwt string, alg string, infile string) error {
 	return nil
 }
 
-// SearchFounds sends a POST request to the /api/search route of the specified URL
+// SearchFounds sends a POST request to the /api/search route of the client URL
 // with the hashes read from the specified file.
 //
 // The function prints the found hashes and their plaintext values and returns any error that occurred.
-func SearchFounds(url string, jwt string, infile string, query string) error {
+func (c *Client) SearchFounds(ctx context.Context, infile string, query string) error {
 	buf, err := os.Open(infile)
 	if err != nil {
 		return err
@@ -307,7 +182,7 @@ func SearchFounds(url string, jwt string, infile string, query string) error {
 	}
 
 	fullPath := fmt.Sprintf("/search?%s", query)
-	res, err := PostRequest(url, fullPath, string(encjson), jwt)
+	res, err := c.postIdempotent(ctx, fullPath, encjson)
 	if err != nil {
 		return err
 	}
@@ -318,10 +193,10 @@ func SearchFounds(url string, jwt string, infile string, query string) error {
 	if body["found"] == "[]" {
 		fmt.Println("")
 	} else if body["found"] != nil {
-		for _, c := range body["found"].([]interface{}) {
-			a := c.(map[string]interface{})["algorithm"]
-			h := c.(map[string]interface{})["hash"]
-			p := c.(map[string]interface{})["plaintext"]
+		for _, f := range body["found"].([]interface{}) {
+			a := f.(map[string]interface{})["algorithm"]
+			h := f.(map[string]interface{})["hash"]
+			p := f.(map[string]interface{})["plaintext"]
 
 			fmt.Println(fmt.Sprintf("%s | %s:%s", a, h, p))
 		}
@@ -331,26 +206,22 @@ func SearchFounds(url string, jwt string, infile string, query string) error {
 	return nil
 }
 
-// DownloadResource sends a POST request to the /api/download/FILE/NUm route of
-// the specified URL
+// DownloadResource sends a GET request to the /api/download/FILE/NUM route of
+// the client URL
 //
-// The function prints lines from the files and returns any error that occured.
-func DownloadResource(url string, jwt string, path string, num string, query string) error {
+// The function streams lines from the file to out as they arrive and returns
+// any error that occured.
+func (c *Client) DownloadResource(ctx context.Context, out io.Writer, path string, num string, query string) error {
 	fullPath := fmt.Sprintf("/download/%s/%s?%s", path, num, query)
-	res, err := GetRequest(url, fullPath, jwt)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(res))
-	return nil
+	return c.stream(ctx, request{method: http.MethodGet, route: fullPath, retry: true}, out)
 }
 
-// ListAllPublicLists sends a GET request to the /api/list route of the specified URL.
+// ListAllPrivateLists sends a GET request to the /api/list route of the client URL.
 //
 // The function prints the response body and returns any error that occurred.
-func ListAllPrivateLists(url string, jwt string) error {
+func (c *Client) ListAllPrivateLists(ctx context.Context) error {
 	fullPath := fmt.Sprintf("/lists")
-	res, err := GetRequest(url, fullPath, jwt)
+	res, err := c.GetRequest(ctx, fullPath)
 	if err != nil {
 		return err
 	}
@@ -358,19 +229,19 @@ func ListAllPrivateLists(url string, jwt string) error {
 	var body map[string]interface{}
 	json.Unmarshal(res, &body)
 	fmt.Println(config.PrintColor("Private Files Listing:", "yellow", "%s"))
-	for _, c := range body["files"].([]interface{}) {
-		fmt.Println(config.PrintColor(fmt.Sprintf("Name: %s | Size: %.0f | Created: %s", c.(map[string]interface{})["name"], c.(map[string]interface{})["size"], c.(map[string]interface{})["creation_time"]), "green", "%s"))
+	for _, f := range body["files"].([]interface{}) {
+		fmt.Println(config.PrintColor(fmt.Sprintf("Name: %s | Size: %.0f | Created: %s", f.(map[string]interface{})["name"], f.(map[string]interface{})["size"], f.(map[string]interface{})["creation_time"]), "green", "%s"))
 	}
 
 	return nil
 }
 
-// ListTargetPublicList sends a GET request to the /api/list/LISTNAME route of the specified URL.
+// ListTargetPrivateList sends a GET request to the /api/list/LISTNAME route of the client URL.
 //
 // The function prints the response body and returns any error that occurred.
-func ListTargetPrivateList(url string, jwt string, listname string) error {
+func (c *Client) ListTargetPrivateList(ctx context.Context, listname string) error {
 	fullPath := fmt.Sprintf("/lists/%s", listname)
-	res, err := GetRequest(url, fullPath, jwt)
+	res, err := c.GetRequest(ctx, fullPath)
 	if err != nil {
 		return err
 	}
@@ -378,17 +249,17 @@ func ListTargetPrivateList(url string, jwt string, listname string) error {
 	return nil
 }
 
-// CreateNewPublicList sends a POST request to the /api/lists route of the specified URL
+// CreateNewPrivateList sends a POST request to the /api/lists route of the client URL
 //
 // Content-Type: text/plain
 // The function prints the response body and returns any error that occurred.
-func CreateNewPrivateList(url string, jwt string, infile string, filename string) error {
+func (c *Client) CreateNewPrivateList(ctx context.Context, infile string, filename string) error {
 	fileContent, err := os.ReadFile(infile)
 	if err != nil {
 		return err
 	}
 
-	res, err := PostRequest(url, fmt.Sprintf("/lists?name=%s", filename), string(fileContent), jwt)
+	res, err := c.PostRequest(ctx, fmt.Sprintf("/lists?name=%s", filename), string(fileContent))
 	if err != nil {
 		return err
 	}
@@ -397,17 +268,17 @@ func CreateNewPrivateList(url string, jwt string, infile string, filename string
 	return nil
 }
 
-// UpdateTargetPublicList sends a POST request to the /api/lists/LISTNAME route of the specified URL
+// UpdateTargetPrivateList sends a POST request to the /api/lists/LISTNAME route of the client URL
 //
 // Content-Type: text/plain
 // The function prints the response body and returns any error that occurred.
-func UpdateTargetPrivateList(url string, jwt string, listname string, infile string) error {
+func (c *Client) UpdateTargetPrivateList(ctx context.Context, listname string, infile string) error {
 	fileContent, err := os.ReadFile(infile)
 	if err != nil {
 		return err
 	}
 
-	res, err := PostRequest(url, fmt.Sprintf("/lists/%s", listname), string(fileContent), jwt)
+	res, err := c.PostRequest(ctx, fmt.Sprintf("/lists/%s", listname), string(fileContent))
 	if err != nil {
 		return err
 	}
@@ -416,13 +287,13 @@ func UpdateTargetPrivateList(url string, jwt string, listname string, infile str
 	return nil
 }
 
-// RefreshGeneratedFile sends a GET request to the /api/manage/refresh/FILE route of the specified URL
+// RefreshGeneratedFile sends a GET request to the /api/manage/refresh/FILE route of the client URL
 // FILE can be "masks", "rules", or "wordlist"
 //
 // The function prints the response body and returns any error that occurred.
-func RefreshGeneratedFile(url string, jwt string, file string) error {
+func (c *Client) RefreshGeneratedFile(ctx context.Context, file string) error {
 	fullPath := fmt.Sprintf("/manage/refresh/%s", file)
-	res, err := GetRequest(url, fullPath, jwt)
+	res, err := c.GetRequest(ctx, fullPath)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// DefaultTimeout is the time limit applied to a single request when the
+// configuration does not set one
+const DefaultTimeout = 5 * time.Minute
+
+// maxErrorBodyRead limits how much of an error response is read
+const maxErrorBodyRead = 64 * 1024
+
+// DefaultUserAgent is sent with every request unless the client overrides it
+const DefaultUserAgent = "ohaclient"
+
+// Client holds the connection settings shared by every call to the server API.
+//
+// A single Client should be reused for the lifetime of the process so the
+// underlying Transport can keep connections alive between requests.
+type Client struct {
+	BaseURL   string
+	UserAgent string
+	Timeout   time.Duration
+	Retry     RetryPolicy
+	Debug     bool
+	Transport *http.Transport
+
+	// mu guards jwt, which may be replaced by a re-login while other
+	// requests are in flight
+	mu  sync.Mutex
+	jwt string
+
+	// loginMu serializes re-logins and the credentials used for them
+	loginMu  sync.Mutex
+	username string
+	password func() (string, error)
+}
+
+// NewClient returns a Client for the API at baseURL with a pooled transport
+// and the default timeouts.
+//
+// TLS, proxy and retry settings are taken from the provided configuration.
+// The function returns the client and any error that occurred.
+func NewClient(baseURL string, conf models.Configuration) (*Client, error) {
+	tlsConfig, err := NewTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	retry := DefaultRetryPolicy
+	if conf.RetryAttempts > 0 {
+		retry.MaxAttempts = conf.RetryAttempts
+	}
+
+	proxy, err := ProxyFunc(conf.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		ForceAttemptHTTP2:     true,
+	}
+
+	return &Client{
+		BaseURL:   baseURL,
+		UserAgent: DefaultUserAgent,
+		Timeout:   DefaultTimeout,
+		Retry:     retry,
+		Transport: tr,
+	}, nil
+}
+
+// ProxyFunc returns the proxy selector for the transport.
+//
+// An explicit proxy URL using the http, https, socks5 or socks5h scheme is
+// used for every request. Without one, the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables are honored.
+func ProxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		// The parse error quotes the URL, which may hold credentials
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy URL: %s", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy scheme %q: expected http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL: missing host")
+	}
+
+	return http.ProxyURL(proxyURL), nil
+}
+
+// token returns the JWT currently held by the client
+func (c *Client) token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.jwt
+}
+
+// setToken replaces the JWT held by the client
+func (c *Client) setToken(jwt string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jwt = jwt
+}
+
+// httpClient wraps the shared transport with the configured timeout
+func (c *Client) httpClient() *http.Client {
+	return &http.Client{Transport: c.Transport, Timeout: c.Timeout}
+}
+
+// request describes a single call to the server API
+type request struct {
+	method      string
+	route       string
+	body        []byte
+	contentType string
+	retry       bool
+}
+
+// do sends r to the server and returns the response body as a byte slice and
+// any error that occurred.
+//
+// Requests marked as retryable are resent on transient failures following
+// the client RetryPolicy. Responses with a non-2xx status code are returned as
+// an *APIError.
+func (c *Client) do(ctx context.Context, r request) ([]byte, error) {
+	var buf bytes.Buffer
+	err := c.withRetry(ctx, r, func() (bool, time.Duration, error) {
+		buf.Reset()
+		_, retryAfter, err := c.send(ctx, r, &buf)
+		return false, retryAfter, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// stream sends r to the server and copies a successful response body to w as
+// it arrives.
+//
+// Retries follow the same rules as do, except that a request is never resent
+// once part of the response has been written to w.
+func (c *Client) stream(ctx context.Context, r request, w io.Writer) error {
+	return c.withRetry(ctx, r, func() (bool, time.Duration, error) {
+		return c.send(ctx, r, w)
+	})
+}
+
+// withRetry runs attempt following the retry policy for r.
+//
+// When the server rejects the token with a 401 and the client holds
+// credentials, it logs in again once and replays the request with the new
+// token.
+func (c *Client) withRetry(ctx context.Context, r request, attempt func() (bool, time.Duration, error)) error {
+	used := c.token()
+	err := c.retryLoop(ctx, r, attempt)
+	if !errors.Is(err, ErrUnauthorized) || used == "" || r.route == "/login" {
+		return err
+	}
+
+	if lerr := c.reauthenticate(ctx, used); lerr != nil {
+		return fmt.Errorf("token was rejected and re-login failed: %w", lerr)
+	}
+	return c.retryLoop(ctx, r, attempt)
+}
+
+// retryLoop runs attempt until it succeeds, fails permanently, reports that
+// output was already written or the retry budget for r is exhausted
+func (c *Client) retryLoop(ctx context.Context, r request, attempt func() (bool, time.Duration, error)) error {
+	attempts := 1
+	if r.retry && c.Retry.MaxAttempts > 1 {
+		attempts = c.Retry.MaxAttempts
+	}
+
+	for n := 1; ; n++ {
+		written, retryAfter, err := attempt()
+		if err == nil || written || n >= attempts || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+
+		delay := c.Retry.backoff(n, retryAfter)
+		fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[!] %s %s failed (attempt %d/%d): %s. Retrying in %s.", r.method, r.route, n, attempts, err, delay.Round(time.Millisecond)), "yellow", "%s"))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send performs a single attempt of r, copying a successful response body to w.
+//
+// If the client holds a JWT, an Authorization header is added to the request.
+// The function returns whether any of the body was written, any Retry-After
+// delay requested by the server and any error that occurred.
+func (c *Client) send(ctx context.Context, r request, w io.Writer) (bool, time.Duration, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+
+	reqURL := fmt.Sprintf("%s%s", c.BaseURL, r.route)
+	req, err := http.NewRequestWithContext(ctx, r.method, reqURL, body)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if jwt := c.token(); jwt != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
+	}
+
+	if r.contentType != "" {
+		req.Header.Add("Content-Type", r.contentType)
+	}
+
+	id := newRequestID()
+	req.Header.Set("X-Request-ID", id)
+	req.Header.Set("User-Agent", c.UserAgent)
+	c.logRequest(req, id, r.body)
+
+	start := time.Now()
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		c.logResponse(req, id, nil, start, nil, err)
+		return false, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyRead))
+		c.logResponse(req, id, res, start, resBody, err)
+		if err != nil {
+			return false, 0, err
+		}
+		path, _, _ := strings.Cut(r.route, "?")
+		return false, parseRetryAfter(res.Header.Get("Retry-After")), newAPIError(res.StatusCode, path, resBody)
+	}
+
+	n, err := io.Copy(w, res.Body)
+	if buf, ok := w.(*bytes.Buffer); ok {
+		// do collects the whole body, so it can be parsed and redacted
+		c.logResponse(req, id, res, start, buf.Bytes(), err)
+	} else {
+		c.logResponse(req, id, res, start, nil, err)
+		c.logStreamed(n)
+	}
+	return n > 0, 0, err
+}
+
+// PostRequest sends an HTTP POST request to the specified route with the given data.
+//
+// The request is not retried since the route may not be safe to resend.
+// Non-2xx responses are returned as an *APIError.
+// The function returns the response body as a byte slice and any error that occurred.
+func (c *Client) PostRequest(ctx context.Context, route string, data string) ([]byte, error) {
+	return c.do(ctx, request{method: http.MethodPost, route: route, body: []byte(data), contentType: "application/json"})
+}
+
+// postIdempotent sends an HTTP POST request that is safe to resend, allowing
+// it to be retried on transient failures
+func (c *Client) postIdempotent(ctx context.Context, route string, data []byte) ([]byte, error) {
+	return c.do(ctx, request{method: http.MethodPost, route: route, body: data, contentType: "application/json", retry: true})
+}
+
+// GetRequest sends an HTTP GET request to the specified route.
+//
+// Transient failures are retried following the client RetryPolicy.
+// Non-2xx responses are returned as an *APIError.
+// The function returns the response body as a byte slice and any error that occurred.
+func (c *Client) GetRequest(ctx context.Context, route string) ([]byte, error) {
+	return c.do(ctx, request{method: http.MethodGet, route: route, retry: true})
+}
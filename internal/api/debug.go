@@ -0,0 +1,142 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+)
+
+// maxDebugBody limits how much of a request or response body is logged
+const maxDebugBody = 512
+
+// redacted replaces secret values in debug output
+const redacted = "[REDACTED]"
+
+// secretKeys are JSON fields whose values are never logged
+var secretKeys = map[string]bool{
+	"password":  true,
+	"token":     true,
+	"plaintext": true,
+}
+
+// newRequestID returns a random identifier sent as X-Request-ID so a request
+// can be matched with the server logs
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// debugf writes a trace line to stderr
+func debugf(format string, args ...interface{}) {
+	fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[debug] "+format, args...), "magenta", "%s"))
+}
+
+// logRequest traces an outgoing request with secrets masked
+func (c *Client) logRequest(req *http.Request, id string, body []byte) {
+	if !c.Debug {
+		return
+	}
+
+	debugf("-> %s %s id=%s", req.Method, req.URL, id)
+	for name, values := range req.Header {
+		value := strings.Join(values, ", ")
+		if name == "Authorization" {
+			value = "Bearer " + redacted
+		}
+		debugf("   %s: %s", name, value)
+	}
+	if len(body) > 0 {
+		debugf("   body (%d bytes): %s", len(body), redactBody(body))
+	}
+}
+
+// logResponse traces the result of a request with secrets masked
+func (c *Client) logResponse(req *http.Request, id string, res *http.Response, start time.Time, body []byte, err error) {
+	if !c.Debug {
+		return
+	}
+
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		debugf("<- %s %s id=%s error after %s: %s", req.Method, req.URL.Path, id, latency, err)
+		return
+	}
+
+	if serverID := res.Header.Get("X-Request-ID"); serverID != "" {
+		id = serverID
+	}
+	debugf("<- %s %s %s id=%s in %s", res.Status, req.Method, req.URL.Path, id, latency)
+	if len(body) > 0 {
+		debugf("   body: %s", redactBody(body))
+	}
+}
+
+// logStreamed traces the size of a response body that was streamed to its
+// destination and therefore cannot be redacted
+func (c *Client) logStreamed(n int64) {
+	if !c.Debug {
+		return
+	}
+	debugf("   body: %d bytes streamed, not logged", n)
+}
+
+// redactBody masks passwords, tokens and plaintext values in a complete JSON
+// body and truncates the result for logging.
+//
+// Bodies that are not valid JSON cannot be redacted, so only their size is
+// logged.
+func redactBody(body []byte) string {
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return fmt.Sprintf("%d bytes, not JSON, not logged", len(body))
+	}
+
+	masked, err := json.Marshal(redactValue("", parsed))
+	if err != nil {
+		return fmt.Sprintf("%d bytes, not logged", len(body))
+	}
+
+	out := string(masked)
+	if len(out) > maxDebugBody {
+		out = fmt.Sprintf("%s... (truncated)", out[:maxDebugBody])
+	}
+	return out
+}
+
+// redactValue walks a decoded JSON value and masks secrets below key
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactValue(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(key, child)
+		}
+		return v
+	case string:
+		if secretKeys[key] {
+			return redacted
+		}
+		if key == "hash-plain" {
+			if hash, _, found := strings.Cut(v, ":"); found {
+				return hash + ":" + redacted
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	var found []map[string]string
+	for i := 0; i < 20; i++ {
+		found = append(found, map[string]string{
+			"algorithm": "0",
+			"hash":      fmt.Sprintf("%032x", i),
+			"plaintext": fmt.Sprintf("SECRETPLAIN%d", i),
+		})
+	}
+	long, err := json.Marshal(map[string]interface{}{"found": found})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		notWant string
+	}{
+		{"short", `{"username":"bob","password":"Secret1234!"}`, redacted, "Secret1234!"},
+		{"longer than the log limit", string(long), "(truncated)", "SECRETPLAIN"},
+		{"hash-plain", `{"hash-plain":["5f4dcc3b5aa765d61d8327deb882cf99:password"]}`, "5f4dcc3b5aa765d61d8327deb882cf99:" + redacted, ":password"},
+		{"not JSON", "password=Secret1234!", "not logged", "Secret1234!"},
+		{"truncated JSON", string(long[:600]), "not logged", "SECRETPLAIN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody([]byte(tt.body))
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("redactBody() = %q, want it to contain %q", got, tt.want)
+			}
+			if strings.Contains(got, tt.notWant) {
+				t.Errorf("redactBody() = %q, leaks %q", got, tt.notWant)
+			}
+		})
+	}
+}
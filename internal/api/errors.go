@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched with errors.Is against an *APIError
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrTooLarge     = errors.New("request too large")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// maxErrorBodyLength limits how much of a non-JSON error body is kept
+const maxErrorBodyLength = 200
+
+// APIError is returned when the server responds with a non-2xx status code
+type APIError struct {
+	StatusCode int
+	Route      string
+	Message    string
+}
+
+// Error formats the status, route and server message
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s returned %d %s", e.Route, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s returned %d %s: %s", e.Route, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Unwrap maps the status code to one of the sentinel errors so callers can
+// use errors.Is
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusRequestEntityTooLarge:
+		return ErrTooLarge
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// newAPIError builds an APIError from a response, taking the message from the
+// JSON error field when the server provides one
+func newAPIError(statusCode int, route string, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Route: route}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if msg, ok := parsed["error"]; ok && msg != nil {
+			apiErr.Message = fmt.Sprintf("%v", msg)
+			return apiErr
+		}
+	}
+
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > maxErrorBodyLength {
+		msg = msg[:maxErrorBodyLength] + "..."
+	}
+	apiErr.Message = msg
+	return apiErr
+}
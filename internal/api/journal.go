@@ -0,0 +1,224 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+)
+
+// journalSuffix is appended to the input file name to name its journal
+const journalSuffix = ".oha-journal"
+
+// submitJournal records the progress of a submission so an interrupted run
+// can be resumed.
+//
+// SHA256 covers the bytes before Offset, which are the ones already
+// acknowledged by the server, so resuming only rereads that part of the file.
+type submitJournal struct {
+	Path        string        `json:"path"`
+	Size        int64         `json:"size"`
+	ModTime     time.Time     `json:"mtime"`
+	SHA256      string        `json:"sha256"`
+	Algorithm   string        `json:"algorithm"`
+	Offset      int64         `json:"offset"`
+	Line        int64         `json:"line"`
+	RejectsSize int64         `json:"rejects-size"`
+	Summary     submitSummary `json:"summary"`
+	Updated     time.Time     `json:"updated"`
+}
+
+// JournalPath returns the path of the journal kept next to infile
+func JournalPath(infile string) string {
+	return infile + journalSuffix
+}
+
+// newJournal returns an empty journal for the input file described by info
+func newJournal(infile string, info os.FileInfo, alg string) *submitJournal {
+	path, err := filepath.Abs(infile)
+	if err != nil {
+		path = infile
+	}
+	return &submitJournal{
+		Path:      path,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		SHA256:    hex.EncodeToString(sha256.New().Sum(nil)),
+		Algorithm: alg,
+	}
+}
+
+// loadJournal reads the journal at path
+func loadJournal(path string) (*submitJournal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var j submitJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("invalid journal %s: %w", path, err)
+	}
+	return &j, nil
+}
+
+// save atomically writes the journal to path
+func (j *submitJournal) save(path string) error {
+	j.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(j, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// resumable checks that the input described by current is unchanged since
+// prev was written, hashing the acknowledged part of the file with hasher.
+//
+// The function returns an error describing the first difference found.
+func (prev *submitJournal) resumable(current *submitJournal, hasher *prefixHasher) error {
+	switch {
+	case prev.Algorithm != current.Algorithm:
+		return fmt.Errorf("the journal is for algorithm %s, not %s", prev.Algorithm, current.Algorithm)
+	case prev.Size != current.Size:
+		return fmt.Errorf("the file size changed from %d to %d bytes", prev.Size, current.Size)
+	case !prev.ModTime.Equal(current.ModTime):
+		return fmt.Errorf("the file was modified at %s, since the journal was written", current.ModTime.Format(time.RFC3339))
+	case prev.Offset < 0 || prev.Offset > current.Size:
+		return fmt.Errorf("the journal offset %d is outside the file", prev.Offset)
+	}
+
+	if err := hasher.advance(prev.Offset); err != nil {
+		return err
+	}
+	if sum := hasher.sum(); sum != prev.SHA256 {
+		return errors.New("the contents of the file changed")
+	}
+	return nil
+}
+
+// prefixHasher hashes a file from the start up to a growing offset,
+// independently of other readers of the same file
+type prefixHasher struct {
+	r   *io.SectionReader
+	h   hash.Hash
+	pos int64
+}
+
+// newPrefixHasher returns a prefixHasher for the first size bytes of f
+func newPrefixHasher(f *os.File, size int64) *prefixHasher {
+	return &prefixHasher{r: io.NewSectionReader(f, 0, size), h: sha256.New()}
+}
+
+// advance hashes the bytes up to offset
+func (p *prefixHasher) advance(offset int64) error {
+	n, err := io.CopyN(p.h, p.r, offset-p.pos)
+	p.pos += n
+	return err
+}
+
+// sum returns the hex SHA-256 of the bytes hashed so far
+func (p *prefixHasher) sum() string {
+	return hex.EncodeToString(p.h.Sum(nil))
+}
+
+// checkpoint keeps the journal of a running submission up to date
+type checkpoint struct {
+	path    string
+	journal *submitJournal
+	hasher  *prefixHasher
+	enabled bool
+}
+
+// startCheckpoint prepares the journal of the submission of infile from f.
+//
+// When resume is set and a journal of an earlier run exists, the input is
+// checked for changes and reader is moved to where the last acknowledged
+// batch ended. A journal that cannot be written disables checkpoints unless
+// resuming. The function returns the checkpoint and any error that occurred.
+func startCheckpoint(f *os.File, info os.FileInfo, infile string, alg string, resume bool, reader *batchReader) (*checkpoint, error) {
+	cp := &checkpoint{
+		path:    JournalPath(infile),
+		journal: newJournal(infile, info, alg),
+		hasher:  newPrefixHasher(f, info.Size()),
+		enabled: true,
+	}
+
+	prev, err := loadJournal(cp.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if resume {
+			printWarning(fmt.Sprintf("[!] No journal found at %s. Starting from the beginning", cp.path))
+		}
+	case err != nil:
+		return nil, err
+	case !resume:
+		printWarning(fmt.Sprintf("[!] Discarding the journal of an earlier run at %s. Use --resume to continue it instead", cp.path))
+	default:
+		if err := prev.resumable(cp.journal, cp.hasher); err != nil {
+			return nil, fmt.Errorf("cannot resume %s: %w. Remove %s to start over", infile, err, cp.path)
+		}
+		if err := reader.seek(prev.Offset, prev.Line, prev.Summary.Batches); err != nil {
+			return nil, err
+		}
+		cp.journal.Offset = prev.Offset
+		cp.journal.Line = prev.Line
+		cp.journal.RejectsSize = prev.RejectsSize
+		cp.journal.SHA256 = prev.SHA256
+		cp.journal.Summary = prev.Summary
+		printWarning(fmt.Sprintf("[+] Resuming after line %d (byte offset %d) with %d batches already submitted", prev.Line, prev.Offset, prev.Summary.Batches))
+	}
+
+	if err := cp.journal.save(cp.path); err != nil {
+		if resume {
+			return nil, err
+		}
+		printWarning(fmt.Sprintf("[!] Cannot write the journal, the submission will not be resumable: %s", err))
+		cp.enabled = false
+	}
+	return cp, nil
+}
+
+// record saves the progress after batch b was acknowledged along with every
+// batch before it, and the size of the rejects file at that point
+func (cp *checkpoint) record(b *batch, summary submitSummary, rejectsSize int64) error {
+	if !cp.enabled {
+		return nil
+	}
+
+	if err := cp.hasher.advance(b.End); err != nil {
+		return err
+	}
+	cp.journal.Offset = b.End
+	cp.journal.Line = b.LastLine
+	cp.journal.RejectsSize = rejectsSize
+	cp.journal.SHA256 = cp.hasher.sum()
+	cp.journal.Summary = summary
+	return cp.journal.save(cp.path)
+}
+
+// finish removes the journal once the whole file was submitted
+func (cp *checkpoint) finish() error {
+	if !cp.enabled {
+		return nil
+	}
+	return os.Remove(cp.path)
+}
+
+// printWarning writes a yellow status line to stderr
+func printWarning(message string) {
+	fmt.Fprintln(os.Stderr, config.PrintColor(message, "yellow", "%s"))
+}
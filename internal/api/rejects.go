@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// rejectsSuffix is appended to the input file name to name its rejects file
+const rejectsSuffix = ".rejected"
+
+// RejectsPath returns the path of the file receiving the lines of infile that
+// failed local validation or verification
+func RejectsPath(infile string) string {
+	return infile + rejectsSuffix
+}
+
+// rejectedLine is an input line that failed local validation or verification
+type rejectedLine struct {
+	number int64
+	text   string
+	reason string
+}
+
+// rejectWriter appends rejected lines to the rejects file as tab separated
+// line number, reason and line. The file is only created once a line is
+// rejected.
+type rejectWriter struct {
+	path string
+	f    *os.File
+	size int64
+}
+
+// newRejectWriter prepares the rejects file at path, keeping the first keep
+// bytes written by an earlier run that is being resumed
+func newRejectWriter(path string, keep int64) (*rejectWriter, error) {
+	var err error
+	if keep > 0 {
+		err = os.Truncate(path, keep)
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	w := &rejectWriter{path: path}
+	if info, err := os.Stat(path); err == nil {
+		w.size = info.Size()
+	}
+	return w, nil
+}
+
+// write appends rejects to the file
+func (w *rejectWriter) write(rejects []rejectedLine) error {
+	if len(rejects) == 0 {
+		return nil
+	}
+
+	if w.f == nil {
+		f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		w.f = f
+	}
+
+	var buf bytes.Buffer
+	for _, r := range rejects {
+		fmt.Fprintf(&buf, "%d\t%s\t%s\n", r.number, r.reason, r.text)
+	}
+	n, err := w.f.Write(buf.Bytes())
+	w.size += int64(n)
+	return err
+}
+
+// close closes the rejects file if it was opened
+func (w *rejectWriter) close() error {
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Close()
+}
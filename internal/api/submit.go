@@ -0,0 +1,566 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// Default limits for the batches sent by SubmitFounds
+const (
+	DefaultBatchLines  = 50000
+	DefaultBatchBytes  = 8 << 20
+	DefaultWorkers     = 1
+	DefaultMaxInFlight = 64 << 20
+
+	// MaxWorkers matches the idle connections kept per host so every
+	// worker reuses a pooled connection
+	MaxWorkers = 16
+)
+
+// SubmitOptions controls how SubmitFounds splits a file into batches
+type SubmitOptions struct {
+	// BatchLines is the most HASH:PLAIN lines sent in one request
+	BatchLines int
+
+	// BatchBytes is the most bytes of JSON encoded lines sent in one
+	// request. A single line larger than the limit is sent on its own.
+	BatchBytes int64
+
+	// Workers is the number of batches sent concurrently
+	Workers int
+
+	// MaxInFlight caps the bytes of all batches being sent at once. A batch
+	// larger than the cap is sent on its own.
+	MaxInFlight int64
+
+	// Resume continues from the journal of an earlier run of the same file
+	Resume bool
+
+	// Validate checks each line for the algorithm before it is sent. Lines
+	// that fail are written to the rejects file instead.
+	Validate bool
+
+	// Verify recomputes the hash of each plaintext before it is sent. Lines
+	// that do not match are written to the rejects file instead.
+	Verify bool
+}
+
+// withDefaults fills unset limits with the defaults
+func (o SubmitOptions) withDefaults() SubmitOptions {
+	if o.BatchLines <= 0 {
+		o.BatchLines = DefaultBatchLines
+	}
+	if o.BatchBytes <= 0 {
+		o.BatchBytes = DefaultBatchBytes
+	}
+	if o.Workers <= 0 {
+		o.Workers = DefaultWorkers
+	}
+	if o.Workers > MaxWorkers {
+		o.Workers = MaxWorkers
+	}
+	if o.MaxInFlight <= 0 {
+		o.MaxInFlight = DefaultMaxInFlight
+	}
+	return o
+}
+
+// batch holds consecutive non-empty lines of a founds file
+type batch struct {
+	Number    int
+	FirstLine int64
+	LastLine  int64
+	Start     int64
+	End       int64
+	Lines     []string
+	Numbers   []int64
+	Count     int
+	Size      int64
+	Rejects   []rejectedLine
+
+	// Verified, Mismatched and Unsupported count the outcome of verifying
+	// the lines of the batch
+	Verified    int
+	Mismatched  int
+	Unsupported int
+}
+
+// pendingLine is a line read past the end of the previous batch
+type pendingLine struct {
+	text   string
+	number int64
+	start  int64
+	end    int64
+}
+
+// batchReader splits a stream of lines into batches without holding more
+// than one batch in memory
+type batchReader struct {
+	src     io.Reader
+	r       *bufio.Reader
+	opts    SubmitOptions
+	offset  int64
+	line    int64
+	batches int
+	pending *pendingLine
+
+	// validate returns why a line is rejected, or nil to send it
+	validate func(line string) error
+}
+
+// newBatchReader returns a batchReader reading r
+func newBatchReader(r io.Reader, opts SubmitOptions) *batchReader {
+	return &batchReader{src: r, r: bufio.NewReaderSize(r, 1<<20), opts: opts.withDefaults()}
+}
+
+// seek continues reading after the given byte offset, line and batch. The
+// source must implement io.Seeker.
+func (b *batchReader) seek(offset int64, line int64, batches int) error {
+	seeker, ok := b.src.(io.Seeker)
+	if !ok {
+		return errors.New("input does not support seeking")
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	b.r.Reset(b.src)
+	b.offset = offset
+	b.line = line
+	b.batches = batches
+	return nil
+}
+
+// Next returns the next batch, or io.EOF when the input is exhausted
+func (b *batchReader) Next() (*batch, error) {
+	next := &batch{Number: b.batches + 1}
+	for len(next.Lines)+len(next.Rejects) < b.opts.BatchLines {
+		line, err := b.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var reason error
+		size := jsonStringSize(line.text) + 1
+		if b.validate != nil {
+			reason = b.validate(line.text)
+		}
+		if reason == nil && len(next.Lines) > 0 && next.Size+size > b.opts.BatchBytes {
+			b.pending = line
+			break
+		}
+
+		if next.FirstLine == 0 {
+			next.FirstLine = line.number
+			next.Start = line.start
+		}
+		next.LastLine = line.number
+		next.End = line.end
+		if reason != nil {
+			next.Rejects = append(next.Rejects, rejectedLine{number: line.number, text: line.text, reason: reason.Error()})
+			continue
+		}
+		next.Lines = append(next.Lines, line.text)
+		next.Numbers = append(next.Numbers, line.number)
+		next.Count++
+		next.Size += size
+	}
+
+	if next.FirstLine == 0 {
+		return nil, io.EOF
+	}
+	b.batches++
+	return next, nil
+}
+
+// readLine returns the next non-empty line with its line number and byte
+// offsets
+func (b *batchReader) readLine() (*pendingLine, error) {
+	if b.pending != nil {
+		line := b.pending
+		b.pending = nil
+		return line, nil
+	}
+
+	for {
+		raw, err := b.r.ReadString('\n')
+		if raw == "" {
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		start := b.offset
+		b.offset += int64(len(raw))
+		b.line++
+		text := strings.TrimRight(raw, "\r\n")
+		if text == "" {
+			continue
+		}
+		return &pendingLine{text: text, number: b.line, start: start, end: b.offset}, nil
+	}
+}
+
+// jsonStringSize returns the length of s once encoded as a JSON string by
+// encoding/json, including the quotes
+func jsonStringSize(s string) int64 {
+	size := int64(2)
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && width == 1:
+			size += 6
+		case r == '"' || r == '\\' || r == '\n' || r == '\r' || r == '\t':
+			size += 2
+		case r < 0x20 || r == '<' || r == '>' || r == '&' || r == '\u2028' || r == '\u2029':
+			size += 6
+		default:
+			size += int64(width)
+		}
+		i += width
+	}
+	return size
+}
+
+// submitSummary totals the numeric fields of the server responses
+type submitSummary struct {
+	Batches     int                `json:"batches"`
+	Lines       int64              `json:"lines"`
+	Rejected    int64              `json:"rejected"`
+	Verified    int64              `json:"verified"`
+	Mismatched  int64              `json:"mismatched"`
+	Unsupported int64              `json:"unsupported"`
+	Bytes       int64              `json:"bytes"`
+	Counts      map[string]float64 `json:"counts"`
+}
+
+// add records a batch and the numeric fields of its response
+func (s *submitSummary) add(b *batch, counts map[string]float64) {
+	s.Batches++
+	s.Lines += int64(b.Count)
+	s.Rejected += int64(len(b.Rejects))
+	s.Verified += int64(b.Verified)
+	s.Mismatched += int64(b.Mismatched)
+	s.Unsupported += int64(b.Unsupported)
+	s.Bytes += b.Size
+	if s.Counts == nil {
+		s.Counts = map[string]float64{}
+	}
+	for key, value := range counts {
+		s.Counts[key] += value
+	}
+}
+
+// formatCounts formats counts as sorted key=value pairs
+func formatCounts(counts map[string]float64) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, strconv.FormatFloat(counts[key], 'f', -1, 64)))
+	}
+	if len(pairs) == 0 {
+		return "no counts returned"
+	}
+	return strings.Join(pairs, " ")
+}
+
+// print writes the aggregate of every batch sent
+func (s submitSummary) print() {
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Submitted %d batches with %d lines (%s), %d rejected: %s", s.Batches, s.Lines, models.FormatByteSize(s.Bytes), s.Rejected, formatCounts(s.Counts)), "yellow", "%s"))
+}
+
+// printVerification writes the outcome of verifying the submitted lines
+func (s submitSummary) printVerification() {
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Verified %d, mismatched %d, unsupported %d", s.Verified, s.Mismatched, s.Unsupported), "yellow", "%s"))
+}
+
+// batchResult is the outcome of sending one batch
+type batchResult struct {
+	batch  *batch
+	counts map[string]float64
+	err    error
+}
+
+// byteLimiter bounds the total size of the batches in flight
+type byteLimiter struct {
+	mu       sync.Mutex
+	limit    int64
+	used     int64
+	released chan struct{}
+}
+
+// newByteLimiter returns a byteLimiter allowing limit bytes in flight
+func newByteLimiter(limit int64) *byteLimiter {
+	return &byteLimiter{limit: limit, released: make(chan struct{})}
+}
+
+// acquire waits until n bytes fit under the limit or ctx is done
+func (l *byteLimiter) acquire(ctx context.Context, n int64) error {
+	n = min(n, l.limit)
+	for {
+		l.mu.Lock()
+		if l.used+n <= l.limit {
+			l.used += n
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release returns n bytes acquired earlier and wakes any waiters
+func (l *byteLimiter) release(n int64) {
+	n = min(n, l.limit)
+	l.mu.Lock()
+	l.used -= n
+	close(l.released)
+	l.released = make(chan struct{})
+	l.mu.Unlock()
+}
+
+// SubmitFounds sends the HASH:PLAIN lines of the specified file to the
+// /api/found route of the client URL with the given algorithm.
+//
+// The file is streamed and sent in batches limited by opts so files larger
+// than memory or the server body limit can be submitted. Up to opts.Workers
+// batches are sent concurrently over the shared connection pool while the
+// batches in flight stay under opts.MaxInFlight bytes. Batches are safe to
+// resend and are retried on transient failures. The first batch that fails
+// stops every worker. With opts.Verify the hashes are recomputed before each
+// batch is sent and lines that do not match are dropped.
+//
+// The function prints a summary of each batch in file order and the totals
+// accepted by the server, and returns any error that occurred.
+func (c *Client) SubmitFounds(ctx context.Context, alg string, infile string, opts SubmitOptions) error {
+	opts = opts.withDefaults()
+	f, err := os.Open(infile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	reader := newBatchReader(f, opts)
+	if opts.Validate {
+		reader.validate = func(line string) error {
+			return models.ValidateHashPlain(alg, line)
+		}
+	}
+
+	cp, err := startCheckpoint(f, info, infile, alg, opts.Resume, reader)
+	if err != nil {
+		return err
+	}
+
+	rejects, err := newRejectWriter(RejectsPath(infile), cp.journal.RejectsSize)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
+	summary := cp.journal.Summary
+	next := reader.batches + 1
+
+	limiter := newByteLimiter(opts.MaxInFlight)
+	jobs := make(chan *batch)
+	results := make(chan batchResult)
+
+	var readErr error
+	go func() {
+		defer close(jobs)
+		for {
+			b, err := reader.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr = err
+				cancel(err)
+				return
+			}
+
+			if err := limiter.acquire(ctx, b.Size); err != nil {
+				return
+			}
+			select {
+			case jobs <- b:
+			case <-ctx.Done():
+				limiter.release(b.Size)
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				acquired := b.Size
+				if opts.Verify {
+					verifyBatch(alg, b)
+				}
+
+				// A batch of rejected lines only advances the checkpoint.
+				// Once a batch failed no other batch is posted, so nothing
+				// after the checkpoint reaches the server
+				var counts map[string]float64
+				var err error
+				switch {
+				case ctx.Err() != nil:
+					err = context.Cause(ctx)
+				case b.Count > 0:
+					counts, err = c.submitBatch(ctx, alg, b)
+					if err != nil {
+						err = fmt.Errorf("batch %d (lines %d-%d, byte offset %d): %w", b.Number, b.FirstLine, b.LastLine, b.Start, err)
+						cancel(err)
+					}
+				}
+				b.Lines = nil
+				b.Numbers = nil
+				limiter.release(acquired)
+				results <- batchResult{batch: b, counts: counts, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Report batches in file order as the ones before them complete
+	var firstErr error
+	done := map[int]batchResult{}
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				// The failing worker cancelled the context, so the cause is
+				// the first failure or the interrupt
+				firstErr = context.Cause(ctx)
+				if firstErr == nil {
+					firstErr = r.err
+				}
+			}
+			continue
+		}
+
+		done[r.batch.Number] = r
+		for {
+			r, ok := done[next]
+			if !ok {
+				break
+			}
+			delete(done, next)
+			next++
+
+			b := r.batch
+			summary.add(b, r.counts)
+			progress := 100.0
+			if info.Size() > 0 {
+				progress = float64(b.End) * 100 / float64(info.Size())
+			}
+			fmt.Println(config.PrintColor(fmt.Sprintf("[+] Batch %d: lines %d-%d (%s, %.1f%%), %d rejected: %s", b.Number, b.FirstLine, b.LastLine, models.FormatByteSize(b.Size), progress, len(b.Rejects), formatCounts(r.counts)), "green", "%s"))
+
+			err := rejects.write(b.Rejects)
+			if err == nil {
+				err = cp.record(b, summary, rejects.size)
+			}
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("cannot record batch %d: %w", b.Number, err)
+				cancel(firstErr)
+			}
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = readErr
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = context.Cause(ctx)
+	}
+	if firstErr != nil {
+		if summary.Batches > 0 {
+			summary.print()
+			if opts.Verify {
+				summary.printVerification()
+			}
+		}
+		if cp.enabled {
+			printWarning(fmt.Sprintf("[!] Progress saved to %s. Run submit again with --resume to continue", cp.path))
+		}
+		return firstErr
+	}
+
+	summary.print()
+	if opts.Verify {
+		summary.printVerification()
+	}
+	if summary.Rejected > 0 {
+		printWarning(fmt.Sprintf("[!] %d lines were rejected for mode %s and written to %s", summary.Rejected, alg, rejects.path))
+	}
+	return cp.finish()
+}
+
+// submitBatch posts one batch to /api/found.
+//
+// The function returns the numeric fields of the response and any error that
+// occurred.
+func (c *Client) submitBatch(ctx context.Context, alg string, b *batch) (map[string]float64, error) {
+	jsondata := &models.UploadHashes{Algorithm: alg, HashPlain: b.Lines}
+	encjson, err := json.Marshal(jsondata)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.postIdempotent(ctx, "/found", encjson)
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]interface{}
+	counts := map[string]float64{}
+	if err := json.Unmarshal(res, &body); err != nil {
+		return counts, nil
+	}
+	for key, value := range body {
+		if n, ok := value.(float64); ok {
+			counts[key] = n
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// foundServer is a fake /api/found route recording the posted lines
+type foundServer struct {
+	mu     sync.Mutex
+	posted []string
+	fail   func(lines []string) bool
+}
+
+// newFoundServer starts a foundServer failing the requests for which fail
+// returns true and returns a client for it
+func newFoundServer(t *testing.T, fail func(lines []string) bool) (*foundServer, *Client) {
+	t.Helper()
+	fs := &foundServer{fail: fail}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var upload models.UploadHashes
+		if err := json.NewDecoder(r.Body).Decode(&upload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fs.mu.Lock()
+		fs.posted = append(fs.posted, upload.HashPlain...)
+		failing := fs.fail != nil && fs.fail(upload.HashPlain)
+		fs.mu.Unlock()
+
+		if failing {
+			http.Error(w, `{"message":"rejected"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"message":"OK","total":%d}`, len(upload.HashPlain))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient(srv.URL+"/api", models.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs, client
+}
+
+// lines returns the lines posted so far
+func (fs *foundServer) lines() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]string(nil), fs.posted...)
+}
+
+// founds returns n valid MD5 HASH:PLAIN lines, the line for number i being
+// founds(n)[i-1]
+func founds(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%032x:plain%d", i+1, i+1)
+	}
+	return lines
+}
+
+// writeFounds writes lines to a file in a temporary directory
+func writeFounds(t *testing.T, lines []string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "founds.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// contains reports whether any of lines equals line
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSubmitFoundsStopsAfterFailedBatch(t *testing.T) {
+	lines := founds(50)
+	path := writeFounds(t, lines)
+
+	// Batch 3 holds lines 21-30
+	server, client := newFoundServer(t, func(batch []string) bool {
+		return contains(batch, lines[24])
+	})
+
+	opts := SubmitOptions{BatchLines: 10, Workers: 1, Validate: true}
+	err := client.SubmitFounds(context.Background(), "0", path, opts)
+	if err == nil || !strings.Contains(err.Error(), "batch 3 ") {
+		t.Fatalf("SubmitFounds() error = %v, want a failure of batch 3", err)
+	}
+
+	posted := server.lines()
+	for _, line := range lines[30:] {
+		if contains(posted, line) {
+			t.Fatalf("line %q after the failed batch was posted", line)
+		}
+	}
+}
+
+// resumeFixture returns 60 lines where every seventh one is invalid, so
+// batches of 10 lines hold rejects
+func resumeFixture() []string {
+	lines := founds(60)
+	for i := 7; i <= len(lines); i += 7 {
+		lines[i-1] = fmt.Sprintf("invalid%d", i)
+	}
+	return lines
+}
+
+// failBatch3 fails the batch holding lines 21-30 of resumeFixture
+func failBatch3(lines []string) func(batch []string) bool {
+	return func(batch []string) bool {
+		return contains(batch, lines[24])
+	}
+}
+
+// interruptedSubmit submits path with batch 3 failing and checks that the
+// journal was kept
+func interruptedSubmit(t *testing.T, lines []string, path string, opts SubmitOptions) {
+	t.Helper()
+	_, client := newFoundServer(t, failBatch3(lines))
+	if err := client.SubmitFounds(context.Background(), "0", path, opts); err == nil {
+		t.Fatal("SubmitFounds() succeeded, want a failure of batch 3")
+	}
+	if _, err := os.Stat(JournalPath(path)); err != nil {
+		t.Fatalf("journal not kept after the failure: %s", err)
+	}
+}
+
+func TestSubmitFoundsResume(t *testing.T) {
+	lines := resumeFixture()
+	path := writeFounds(t, lines)
+	opts := SubmitOptions{BatchLines: 10, Workers: 1, Validate: true}
+	interruptedSubmit(t, lines, path, opts)
+
+	// A reject written after the last checkpoint, as by a crash between
+	// writing the rejects and the journal, is dropped on resume
+	f, err := os.OpenFile(RejectsPath(path), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("21\tstale\tinvalid21\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	server, client := newFoundServer(t, nil)
+	opts.Resume = true
+	if err := client.SubmitFounds(context.Background(), "0", path, opts); err != nil {
+		t.Fatalf("resumed SubmitFounds() error = %v", err)
+	}
+
+	// Lines 1-20 were acknowledged before batch 3 failed
+	var want []string
+	for i, line := range lines[20:] {
+		if (i+21)%7 != 0 {
+			want = append(want, line)
+		}
+	}
+	if got := server.lines(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("resume posted %d lines %q, want the %d unacknowledged lines %q", len(got), got, len(want), want)
+	}
+
+	data, err := os.ReadFile(RejectsPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []string
+	for _, row := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		number, _, _ := strings.Cut(row, "\t")
+		numbers = append(numbers, number)
+	}
+	if got, want := strings.Join(numbers, ","), "7,14,21,28,35,42,49,56"; got != want {
+		t.Errorf("rejected lines = %s, want %s", got, want)
+	}
+
+	if _, err := os.Stat(JournalPath(path)); !os.IsNotExist(err) {
+		t.Errorf("journal still exists after the submission completed: %v", err)
+	}
+}
+
+func TestSubmitFoundsRefusesChangedInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, path string)
+	}{
+		{"mtime", func(t *testing.T, path string) {
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"size", func(t *testing.T, path string) {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString("extra:line\n"); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"content", func(t *testing.T, path string) {
+			// Change an acknowledged line, keeping the size and mtime
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[0] ^= 1
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := resumeFixture()
+			path := writeFounds(t, lines)
+			opts := SubmitOptions{BatchLines: 10, Workers: 1, Validate: true}
+			interruptedSubmit(t, lines, path, opts)
+			tt.change(t, path)
+
+			server, client := newFoundServer(t, nil)
+			opts.Resume = true
+			err := client.SubmitFounds(context.Background(), "0", path, opts)
+			if err == nil || !strings.Contains(err.Error(), "cannot resume") {
+				t.Fatalf("resumed SubmitFounds() error = %v, want a refusal", err)
+			}
+			if posted := server.lines(); len(posted) > 0 {
+				t.Errorf("refused resume posted %d lines", len(posted))
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// NewTLSConfig builds the TLS settings used for every connection to the server.
+//
+// Certificates are verified against the system roots plus the optional
+// ca-file. When client-cert and client-key are set the pair is presented for
+// mutual TLS authentication. When pin-sha256 is set the server must also
+// present a certificate whose SubjectPublicKeyInfo hashes to the pin.
+// Verification is only skipped when insecure is set, and a warning is printed
+// when it is.
+//
+// The function returns the TLS configuration and any error that occurred.
+func NewTLSConfig(conf models.Configuration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if conf.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CA file: %s", err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA file: %s", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.ClientCert != "" || conf.ClientKey != "" {
+		cert, err := loadClientCertificate(conf.ClientCert, conf.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if conf.PinSHA256 != "" {
+		pin, err := decodePin(conf.PinSHA256)
+		if err != nil {
+			return nil, err
+		}
+		insecure := conf.Insecure
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if matchesPin(pinCandidates(cs, insecure), pin) {
+				return nil
+			}
+			return errors.New("server certificate does not match the configured pin-sha256")
+		}
+	}
+
+	if conf.Insecure {
+		fmt.Fprintln(os.Stderr, config.PrintColor("[!] WARNING: TLS certificate verification is DISABLED. Traffic to the OHA Server can be intercepted.", "red", "%s"))
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	return tlsConfig, nil
+}
+
+// pinCandidates returns the certificates a pin may match.
+//
+// Extra certificates sent by the peer are not verified, so only the verified
+// chains are trusted. Without verification only the leaf is trusted, since
+// the handshake proves the server holds its key.
+func pinCandidates(cs tls.ConnectionState, insecure bool) []*x509.Certificate {
+	if insecure {
+		if len(cs.PeerCertificates) == 0 {
+			return nil
+		}
+		return cs.PeerCertificates[:1]
+	}
+
+	var certs []*x509.Certificate
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	return certs
+}
+
+// matchesPin reports whether the SubjectPublicKeyInfo of any of certs hashes
+// to pin
+func matchesPin(certs []*x509.Certificate, pin []byte) bool {
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if string(sum[:]) == string(pin) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadClientCertificate loads the PEM encoded client certificate and key used
+// for mutual TLS and checks that the pair matches and is currently valid
+func loadClientCertificate(certFile string, keyFile string) (tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, errors.New("client-cert and client-key must both be set for client certificate authentication")
+	}
+
+	if _, err := os.Stat(certFile); err != nil {
+		return tls.Certificate{}, fmt.Errorf("client certificate not found: %s", certFile)
+	}
+
+	if _, err := os.Stat(keyFile); err != nil {
+		return tls.Certificate{}, fmt.Errorf("client key not found: %s", keyFile)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		if strings.Contains(err.Error(), "does not match") {
+			return tls.Certificate{}, fmt.Errorf("client key %s does not match client certificate %s", keyFile, certFile)
+		}
+		return tls.Certificate{}, fmt.Errorf("Error loading client certificate: %s", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("Error parsing client certificate: %s", err)
+	}
+
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return tls.Certificate{}, fmt.Errorf("client certificate %s expired on %s", certFile, leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	if now.Before(leaf.NotBefore) {
+		return tls.Certificate{}, fmt.Errorf("client certificate %s is not valid until %s", certFile, leaf.NotBefore.Format(time.RFC3339))
+	}
+
+	cert.Leaf = leaf
+	return cert, nil
+}
+
+// decodePin parses a SHA-256 SPKI pin in base64 (optionally prefixed with
+// "sha256/") or hex form
+func decodePin(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "sha256/")
+
+	if pin, err := base64.StdEncoding.DecodeString(s); err == nil && len(pin) == sha256.Size {
+		return pin, nil
+	}
+
+	if pin, err := hex.DecodeString(strings.ReplaceAll(s, ":", "")); err == nil && len(pin) == sha256.Size {
+		return pin, nil
+	}
+
+	return nil, fmt.Errorf("invalid pin-sha256: expected a base64 or hex SHA-256 digest")
+}
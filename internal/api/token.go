@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+)
+
+// tokenExpirySkew is the minimum remaining lifetime for a cached token to be
+// reused, so it does not expire in the middle of a request
+const tokenExpirySkew = time.Minute
+
+// The cachedToken struct is stored on disk for each server and username
+type cachedToken struct {
+	Server   string    `json:"server"`
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	Expires  time.Time `json:"expires"`
+}
+
+// TokenCachePath returns the file used to cache the JWT for server and
+// username under the user cache directory
+func TokenCachePath(server string, username string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(server + "\x00" + username))
+	return filepath.Join(dir, "ohaclient", "tokens", hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// LoadCachedToken returns the cached JWT for server and username if one
+// exists and is not about to expire.
+//
+// An empty string is returned when no usable token is cached.
+func LoadCachedToken(server string, username string) (string, error) {
+	path, err := TokenCachePath(server, username)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var cached cachedToken
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return "", nil
+	}
+
+	if cached.Server != server || cached.Username != username || time.Until(cached.Expires) < tokenExpirySkew {
+		return "", nil
+	}
+
+	return cached.Token, nil
+}
+
+// SaveCachedToken writes token to the cache for server and username with
+// permissions restricted to the current user.
+//
+// The function returns the token expiry and any error that occurred.
+func SaveCachedToken(server string, username string, token string) (time.Time, error) {
+	expires, err := TokenExpiry(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	path, err := TokenCachePath(server, username)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return time.Time{}, err
+	}
+
+	data, err := json.Marshal(cachedToken{Server: server, Username: username, Token: token, Expires: expires})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return time.Time{}, err
+	}
+
+	return expires, os.Rename(tmp, path)
+}
+
+// ClearCachedToken removes the cached token for server and username
+func ClearCachedToken(server string, username string) error {
+	path, err := TokenCachePath(server, username)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// TokenExpiry decodes the exp claim of a JWT without verifying its signature
+func TokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error decoding token payload: %s", err)
+	}
+
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("Error parsing token claims: %s", err)
+	}
+
+	if claims.Exp == nil {
+		return time.Time{}, errors.New("token has no exp claim")
+	}
+
+	return time.Unix(int64(*claims.Exp), 0), nil
+}
+
+// Authenticate loads a cached JWT for username or logs in and caches the new
+// token when none is usable.
+//
+// password is only called when a login is needed, so a valid cached token
+// avoids prompting or running a password helper. It is kept so the client can
+// log in again if the token is rejected mid-operation. The function returns
+// any error that occurred.
+func (c *Client) Authenticate(ctx context.Context, username string, password func() (string, error)) error {
+	c.loginMu.Lock()
+	c.username = username
+	c.password = password
+	c.loginMu.Unlock()
+
+	token, err := LoadCachedToken(c.BaseURL, username)
+	if err == nil && token != "" {
+		c.setToken(token)
+		return nil
+	}
+
+	return c.login(ctx)
+}
+
+// reauthenticate logs in again after the server rejected the token named by
+// failed.
+//
+// If another request already replaced the token, the new one is reused
+// instead of logging in again.
+func (c *Client) reauthenticate(ctx context.Context, failed string) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	if c.username == "" {
+		return errors.New("no credentials available")
+	}
+
+	if c.token() != failed {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, config.PrintColor("[!] Token rejected by the OHA Server. Logging in again.", "yellow", "%s"))
+	return c.loginLocked(ctx)
+}
+
+// login authenticates with the stored credentials and caches the new token
+func (c *Client) login(ctx context.Context) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	return c.loginLocked(ctx)
+}
+
+// loginLocked is login for callers already holding loginMu.
+//
+// Failing to write the cache is not fatal since the token is still held by the
+// client.
+func (c *Client) loginLocked(ctx context.Context) error {
+	if c.password == nil {
+		return errors.New("no credentials available")
+	}
+	password, err := c.password()
+	if err != nil {
+		return err
+	}
+
+	token, err := c.ServerAuthenticate(ctx, c.username, password)
+	if err != nil {
+		return err
+	}
+
+	if _, err := SaveCachedToken(c.BaseURL, c.username, token); err != nil {
+		fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[!] Unable to cache token: %s", err), "yellow", "%s"))
+	}
+	return nil
+}
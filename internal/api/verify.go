@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// verifyBatch recomputes the hashes of the lines of b for the algorithm alg,
+// spreading the lines over every CPU.
+//
+// Lines whose plaintext does not match are moved to the rejects of the batch
+// and the counts of the batch are updated. Lines of modes that cannot be
+// recomputed are kept and counted as unsupported.
+func verifyBatch(alg string, b *batch) {
+	if !models.CanVerify(alg) {
+		b.Unsupported = b.Count
+		return
+	}
+
+	results := make([]error, len(b.Lines))
+	chunk := (len(b.Lines) + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for start := 0; start < len(b.Lines); start += chunk {
+		end := min(start+chunk, len(b.Lines))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				results[i] = models.VerifyHashPlain(alg, b.Lines[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := b.Lines[:0]
+	numbers := b.Numbers[:0]
+	for i, err := range results {
+		line, number := b.Lines[i], b.Numbers[i]
+		switch {
+		case err == nil:
+			b.Verified++
+		case errors.Is(err, models.ErrUnsupportedMode):
+			b.Unsupported++
+		default:
+			b.Mismatched++
+			b.Count--
+			b.Size -= jsonStringSize(line) + 1
+			b.Rejects = append(b.Rejects, rejectedLine{number: number, text: line, reason: err.Error()})
+			continue
+		}
+		lines = append(lines, line)
+		numbers = append(numbers, number)
+	}
+	b.Lines = lines
+	b.Numbers = numbers
+
+	// Keep the rejects file in line order
+	sort.Slice(b.Rejects, func(i, j int) bool {
+		return b.Rejects[i].number < b.Rejects[j].number
+	})
+}
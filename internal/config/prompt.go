@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// exitInterrupted is the exit code used when a prompt is interrupted
+const exitInterrupted = 130
+
+// stdin is shared by every prompt so buffered input is not lost between calls
+var stdin = bufio.NewReader(os.Stdin)
+
+// IsTerminal reports whether stdin is an interactive terminal
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// Prompt prints label to stderr and returns the line typed by the user
+func Prompt(label string) (string, error) {
+	defer interruptPrompt(func() {})()
+
+	fmt.Fprint(os.Stderr, label)
+	input, err := stdin.ReadString('\n')
+	if err != nil && input == "" {
+		return "", err
+	}
+	return strings.TrimRight(input, "\r\n"), nil
+}
+
+// PromptPassword prints label to stderr and reads a line from the terminal
+// with echo disabled.
+//
+// The function returns an error when stdin is not a terminal.
+func PromptPassword(label string) (string, error) {
+	if !IsTerminal() {
+		return "", errors.New("cannot prompt for a password: stdin is not a terminal")
+	}
+
+	fd := int(os.Stdin.Fd())
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", err
+	}
+	defer interruptPrompt(func() { term.Restore(fd, state) })()
+
+	fmt.Fprint(os.Stderr, label)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// interruptPrompt ends the process when SIGINT or SIGTERM arrives while a
+// prompt waits for input, after calling restore to reset the terminal.
+//
+// The handler cancelling the running command would otherwise catch the
+// signal and leave the prompt waiting. The function returns a function that
+// stops watching for signals.
+func interruptPrompt(restore func()) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			restore()
+			fmt.Fprintln(os.Stderr)
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
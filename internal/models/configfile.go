@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReadConfigValues returns the raw JSON object stored in the config file at
+// path so it can be edited without losing unknown keys.
+//
+// A missing file yields an empty map.
+func ReadConfigValues(path string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	fileContent, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file: %s", path)
+	}
+
+	if err := json.Unmarshal(fileContent, &values); err != nil {
+		return nil, fmt.Errorf("Error parsing file: %s: %s", path, err)
+	}
+	return values, nil
+}
+
+// WriteConfigFile writes values as indented JSON to path with permissions
+// restricted to the current user
+func WriteConfigFile(path string, values map[string]interface{}) error {
+	data, err := json.MarshalIndent(values, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// SetConfigValue validates value for key and stores it in the config file at
+// path.
+//
+// When profile is set the value is stored within that profile. Timeouts are
+// set with keys of the form timeouts.OPERATION.
+func SetConfigValue(path string, profile string, key string, value string) error {
+	var typed interface{}
+	if op, ok := strings.CutPrefix(key, "timeouts."); ok {
+		if op == "" {
+			return fmt.Errorf("invalid key %s: expected timeouts.OPERATION", key)
+		}
+		if timeout, err := time.ParseDuration(value); err != nil || timeout < 0 {
+			return fmt.Errorf("invalid timeout for %s: %s", op, value)
+		}
+		typed = value
+	} else {
+		field, ok := LookupConfigField(key)
+		if !ok {
+			return fmt.Errorf("unknown configuration key: %s", key)
+		}
+
+		var err error
+		typed, err = field.JSONValue(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	values, err := ReadConfigValues(path)
+	if err != nil {
+		return err
+	}
+
+	target := values
+	if profile != "" {
+		profiles, _ := values["profiles"].(map[string]interface{})
+		if profiles == nil {
+			profiles = map[string]interface{}{}
+			values["profiles"] = profiles
+		}
+		target, _ = profiles[profile].(map[string]interface{})
+		if target == nil {
+			target = map[string]interface{}{}
+			profiles[profile] = target
+		}
+	}
+
+	if op, ok := strings.CutPrefix(key, "timeouts."); ok {
+		timeouts, _ := target["timeouts"].(map[string]interface{})
+		if timeouts == nil {
+			timeouts = map[string]interface{}{}
+			target["timeouts"] = timeouts
+		}
+		timeouts[op] = typed
+	} else {
+		target[key] = typed
+	}
+
+	return WriteConfigFile(path, values)
+}
+
+// ConfigSearchPaths returns the locations checked for a config file in
+// order: the explicit path, OHA_CONFIG, $XDG_CONFIG_HOME/ohaclient/config.json
+// and the legacy $HOME/.oha.
+//
+// Locations that cannot be determined, such as when HOME is unset, are
+// skipped.
+func ConfigSearchPaths(explicit string) []string {
+	var paths []string
+	if explicit != "" {
+		paths = append(paths, explicit)
+	}
+
+	if env := os.Getenv("OHA_CONFIG"); env != "" {
+		paths = append(paths, env)
+	}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "ohaclient", "config.json"))
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".oha"))
+	}
+
+	return paths
+}
+
+// FindConfig returns the config file to use and whether it exists.
+//
+// An explicit path or OHA_CONFIG is always used, even if the file does not
+// exist yet. Otherwise the first existing search location is used, falling
+// back to the XDG location so new files are not written to the home
+// directory. The function also returns every location that was tried.
+func FindConfig(explicit string) (string, bool, []string) {
+	paths := ConfigSearchPaths(explicit)
+	if len(paths) == 0 {
+		return "", false, paths
+	}
+
+	if explicit != "" || os.Getenv("OHA_CONFIG") != "" {
+		return paths[0], fileExists(paths[0]), paths[:1]
+	}
+
+	for _, path := range paths {
+		if fileExists(path) {
+			return path, true, paths
+		}
+	}
+
+	return paths[0], false, paths
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+)
+
+// ResolvePassword fills in ClientPassword when it is not set directly.
+//
+// The password is taken from the first of password-command, password-file or
+// an interactive prompt with echo disabled. The function returns any error
+// that occurred.
+func ResolvePassword(conf *Configuration) error {
+	if conf.ClientPassword != "" {
+		return nil
+	}
+
+	switch {
+	case conf.PasswordCommand != "":
+		password, err := runPasswordCommand(conf.PasswordCommand)
+		if err != nil {
+			return err
+		}
+		conf.ClientPassword = password
+		conf.SetOrigin("client-password", "password-command")
+	case conf.PasswordFile != "":
+		password, err := readPasswordFile(conf.PasswordFile)
+		if err != nil {
+			return err
+		}
+		conf.ClientPassword = password
+		conf.SetOrigin("client-password", "password-file")
+	default:
+		password, err := config.PromptPassword(fmt.Sprintf("OHA password for %s: ", conf.ClientUsername))
+		if err != nil {
+			return fmt.Errorf("no password configured. Set client-password, password-command or password-file: %s", err)
+		}
+		conf.ClientPassword = password
+		conf.SetOrigin("client-password", "prompt")
+	}
+
+	if conf.ClientPassword == "" {
+		return fmt.Errorf("password source returned an empty password")
+	}
+	return nil
+}
+
+// runPasswordCommand runs command through the system shell and returns the
+// first line it writes to stdout.
+//
+// stdin and stderr are passed through so helpers can prompt the user.
+func runPasswordCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("password-command failed: %s", err)
+	}
+
+	return firstLine(stdout.String()), nil
+}
+
+// readPasswordFile returns the first line of the file at path
+func readPasswordFile(path string) (string, error) {
+	fileContent, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading password-file: %s", err)
+	}
+	return firstLine(string(fileContent)), nil
+}
+
+// firstLine returns s up to the first line break
+func firstLine(s string) string {
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	if scanner.Scan() {
+		return strings.TrimRight(scanner.Text(), "\r")
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// HashLengths maps the supported hashcat modes to the length of their hex
+// digests
+var HashLengths = map[string]int{
+	"0":    32,  // MD5
+	"100":  40,  // SHA1
+	"1000": 32,  // NTLM
+	"1400": 64,  // SHA2-256
+	"1700": 128, // SHA2-512
+}
+
+// ValidateHashPlain checks that line is a HASH:PLAIN pair for the hashcat
+// mode alg.
+//
+// Hashes of modes in HashLengths must be hex of the expected length, other
+// modes only need a non-empty hash. A plaintext in $HEX[...] notation must
+// hold valid hex. The function returns an error giving the reason the line
+// was rejected.
+func ValidateHashPlain(alg string, line string) error {
+	hash, plain, found := strings.Cut(line, ":")
+	if !found {
+		return errors.New("missing HASH:PLAIN separator")
+	}
+	if hash == "" {
+		return errors.New("empty hash")
+	}
+
+	if length, ok := HashLengths[alg]; ok {
+		if len(hash) != length {
+			return fmt.Errorf("hash length %d does not match mode %s (expected %d)", len(hash), alg, length)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return errors.New("hash is not hex")
+		}
+	}
+
+	_, err := DecodePlaintext(plain)
+	return err
+}
+
+// DecodePlaintext returns the bytes of a plaintext, decoding the $HEX[...]
+// notation hashcat uses for plaintexts that are not printable.
+//
+// The function returns the plaintext and any error that occurred.
+func DecodePlaintext(plain string) ([]byte, error) {
+	inner, ok := strings.CutPrefix(plain, "$HEX[")
+	if !ok || !strings.HasSuffix(inner, "]") {
+		return []byte(plain), nil
+	}
+
+	decoded, err := hex.DecodeString(strings.TrimSuffix(inner, "]"))
+	if err != nil {
+		return nil, errors.New("invalid $HEX[] plaintext")
+	}
+	return decoded, nil
+}
+
+// ErrHashMismatch is returned when a plaintext does not hash to its hash
+var ErrHashMismatch = errors.New("plaintext does not match the hash")
+
+// ErrUnsupportedMode is returned for hashcat modes that cannot be recomputed
+// locally
+var ErrUnsupportedMode = errors.New("mode cannot be verified locally")
+
+// hashFuncs computes the candidate digests of a plaintext for the unsalted
+// modes that can be verified
+var hashFuncs = map[string]func(plain []byte) [][]byte{
+	"0": func(plain []byte) [][]byte {
+		sum := md5.Sum(plain)
+		return [][]byte{sum[:]}
+	},
+	"100": func(plain []byte) [][]byte {
+		sum := sha1.Sum(plain)
+		return [][]byte{sum[:]}
+	},
+	"1000": ntlmSums,
+	"1400": func(plain []byte) [][]byte {
+		sum := sha256.Sum256(plain)
+		return [][]byte{sum[:]}
+	},
+	"1700": func(plain []byte) [][]byte {
+		sum := sha512.Sum512(plain)
+		return [][]byte{sum[:]}
+	},
+}
+
+// ntlmSums returns the NTLM digests of plain, the MD4 of its UTF-16LE
+// encoding.
+//
+// Windows encodes the UTF-8 characters while hashcat widens every byte, so
+// both are returned when they differ.
+func ntlmSums(plain []byte) [][]byte {
+	widened := make([]byte, 0, 2*len(plain))
+	for _, c := range plain {
+		widened = append(widened, c, 0)
+	}
+	sum := md4Sum(widened)
+	sums := [][]byte{sum[:]}
+
+	if utf8.Valid(plain) && !isASCII(plain) {
+		var encoded []byte
+		for _, unit := range utf16.Encode([]rune(string(plain))) {
+			encoded = binary.LittleEndian.AppendUint16(encoded, unit)
+		}
+		sum := md4Sum(encoded)
+		sums = append(sums, sum[:])
+	}
+	return sums
+}
+
+// isASCII reports whether b only holds ASCII characters
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// CanVerify reports whether VerifyHashPlain can recompute hashes of mode alg
+func CanVerify(alg string) bool {
+	_, ok := hashFuncs[alg]
+	return ok
+}
+
+// VerifyHashPlain recomputes the hash of the plaintext of the HASH:PLAIN line
+// for the hashcat mode alg and compares it with the hash, ignoring case.
+//
+// The function returns ErrHashMismatch when they differ, ErrUnsupportedMode
+// when the mode cannot be computed locally, and any error that occurred while
+// parsing the line.
+func VerifyHashPlain(alg string, line string) error {
+	hashFunc, ok := hashFuncs[alg]
+	if !ok {
+		return ErrUnsupportedMode
+	}
+	if err := ValidateHashPlain(alg, line); err != nil {
+		return err
+	}
+
+	hash, plain, _ := strings.Cut(line, ":")
+	want, err := hex.DecodeString(hash)
+	if err != nil {
+		return err
+	}
+	decoded, err := DecodePlaintext(plain)
+	if err != nil {
+		return err
+	}
+
+	for _, sum := range hashFunc(decoded) {
+		if bytes.Equal(sum, want) {
+			return nil
+		}
+	}
+	return ErrHashMismatch
+}
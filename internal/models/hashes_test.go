@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMD4Sum(t *testing.T) {
+	// RFC 1320 appendix A.5
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", "043f8582f241db351ce627e153e7f0e4"},
+		{strings.Repeat("1234567890", 8), "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+
+	for _, tt := range tests {
+		sum := md4Sum([]byte(tt.input))
+		if got := hex.EncodeToString(sum[:]); got != tt.want {
+			t.Errorf("md4Sum(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyHashPlain(t *testing.T) {
+	tests := []struct {
+		name string
+		alg  string
+		line string
+		want error
+	}{
+		{"md5", "0", "5f4dcc3b5aa765d61d8327deb882cf99:password", nil},
+		{"md5 uppercase digest", "0", "5F4DCC3B5AA765D61D8327DEB882CF99:password", nil},
+		{"md5 colon in plaintext", "0", "d8160c9b3dc20d4e931aeb4f45262155:a:b", nil},
+		{"md5 $HEX[] plaintext", "0", "afd8ec1942c7a161d3af825bd7a417ed:$HEX[ff0041]", nil},
+		{"md5 mismatch", "0", "5f4dcc3b5aa765d61d8327deb882cf99:Password", ErrHashMismatch},
+		{"sha1", "100", "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d:hello", nil},
+		{"sha256", "1400", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824:hello", nil},
+		{"sha512", "1700", "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043:hello", nil},
+		{"ntlm", "1000", "8846f7eaee8fb117ad06bdd830b7586c:password", nil},
+		{"ntlm uppercase digest", "1000", "8846F7EAEE8FB117AD06BDD830B7586C:password", nil},
+		{"ntlm empty plaintext", "1000", "31d6cfe0d16ae931b73c59d7e0c089c0:", nil},
+		{"ntlm non-ASCII as UTF-16", "1000", "0553152250ac01adb4213cb9938663e4:pässwörd", nil},
+		{"ntlm non-ASCII widened bytes", "1000", "bba7e76a87f61ff6aa300ea899a0540b:pässwörd", nil},
+		{"ntlm $HEX[] plaintext", "1000", "20550a4238cd325eb38accb1b6bfa87c:$HEX[ff41]", nil},
+		{"ntlm mismatch", "1000", "8846f7eaee8fb117ad06bdd830b7586c:Password", ErrHashMismatch},
+		{"unsupported mode", "3200", "$2a$05$abc:password", ErrUnsupportedMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyHashPlain(tt.alg, tt.line); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyHashPlain(%q, %q) = %v, want %v", tt.alg, tt.line, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyHashPlainInvalidLines(t *testing.T) {
+	tests := []struct {
+		name string
+		alg  string
+		line string
+	}{
+		{"missing separator", "0", "5f4dcc3b5aa765d61d8327deb882cf99"},
+		{"short hash", "0", "5f4dcc3b:password"},
+		{"hash not hex", "1000", "zz46f7eaee8fb117ad06bdd830b7586c:password"},
+		{"invalid $HEX[]", "0", "5f4dcc3b5aa765d61d8327deb882cf99:$HEX[zz]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyHashPlain(tt.alg, tt.line)
+			if err == nil || errors.Is(err, ErrHashMismatch) || errors.Is(err, ErrUnsupportedMode) {
+				t.Errorf("VerifyHashPlain(%q, %q) = %v, want a format error", tt.alg, tt.line, err)
+			}
+		})
+	}
+}
+
+func TestDecodePlaintext(t *testing.T) {
+	tests := []struct {
+		plain   string
+		want    string
+		wantErr bool
+	}{
+		{"password", "password", false},
+		{"$HEX[70617373]", "pass", false},
+		{"$HEX[]", "", false},
+		{"$HEX[7]", "", true},
+		{"$HEX[7061", "$HEX[7061", false},
+	}
+
+	for _, tt := range tests {
+		got, err := DecodePlaintext(tt.plain)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("DecodePlaintext(%q) error = %v, want error %v", tt.plain, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(got) != tt.want {
+			t.Errorf("DecodePlaintext(%q) = %q, want %q", tt.plain, got, tt.want)
+		}
+	}
+}
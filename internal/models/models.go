@@ -8,15 +8,36 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 // The Configuration struct is used to load configuration files
 type Configuration struct {
-	ServerURL      string `json:"server-url"`
-	ServerPort     string `json:"server-port"`
-	ServerAPIRoute string `json:"server-api-route"`
-	ClientUsername string `json:"client-username"`
-	ClientPassword string `json:"client-password"`
+	Server          string            `json:"server"`
+	ServerURL       string            `json:"server-url"`
+	ServerPort      string            `json:"server-port"`
+	ServerAPIRoute  string            `json:"server-api-route"`
+	ClientUsername  string            `json:"client-username"`
+	ClientPassword  string            `json:"client-password"`
+	PasswordCommand string            `json:"password-command"`
+	PasswordFile    string            `json:"password-file"`
+	CAFile          string            `json:"ca-file"`
+	PinSHA256       string            `json:"pin-sha256"`
+	ClientCert      string            `json:"client-cert"`
+	ClientKey       string            `json:"client-key"`
+	Insecure        bool              `json:"insecure"`
+	StrictPerms     bool              `json:"strict-permissions"`
+	Proxy           string            `json:"proxy"`
+	RetryAttempts   int               `json:"retry-attempts"`
+	Timeouts        map[string]string `json:"timeouts"`
+
+	// Profile is the name of the profile the values were loaded from
+	Profile string `json:"-"`
+
+	// Origins records where each effective value came from
+	Origins map[string]string `json:"-"`
 }
 
 // The UserCredentials struct is used for authentication
@@ -110,29 +131,59 @@ func ValidateFileInputArgs(args []string, index int) (string, error) {
 // ValidateConfig validates the config from ENV vars
 func ValidateConfig(config Configuration) error {
 	// Validate the server URL
-	serverURL := fmt.Sprintf("https://%s:%s%s", config.ServerURL, config.ServerPort, config.ServerAPIRoute)
-	if !regexp.MustCompile(`^https?:\/\/[a-zA-Z0-9-]+\.[a-zA-Z0-9-]+`).MatchString(serverURL) {
-		return fmt.Errorf("invalid server URL")
-	}
-	// Validate the server port
-	if !regexp.MustCompile(`^[0-9]+`).MatchString(config.ServerPort) {
-		return fmt.Errorf("invalid server port")
+	if _, err := config.ServerBaseURL(); err != nil {
+		return err
 	}
 	// Validate the username
-	if len(config.ClientUsername) < 3 || !regexp.MustCompile(`^[a-zA-Z0-9]`).MatchString(config.ClientUsername) {
-		return fmt.Errorf("Invalid username. Expected at least 3 alphanumeric characters. Got: %s", config.ClientUsername)
+	if err := ValidateUsername(config.ClientUsername); err != nil {
+		return err
 	}
-	// Validate the password
-	if len(config.ClientPassword) < 12 {
-		fmt.Println(config)
-		return fmt.Errorf("passwords must be at least 12 characters long and contain a mix of uppercase, lowercase letters, and at least one digit and one special character")
+	// Validate the password length. The full policy only applies to new
+	// accounts so existing passwords still reach the server
+	if len([]rune(config.ClientPassword)) < MinPasswordLength {
+		return fmt.Errorf("passwords must be at least %d characters long", MinPasswordLength)
 	}
 
 	return nil
 }
 
+// OperationTimeout returns the configured timeout for the named operation.
+//
+// Operations without their own entry fall back to the "default" entry and
+// then to fallback when neither is set. A timeout of "0" disables the limit.
+func (c Configuration) OperationTimeout(operation string, fallback time.Duration) (time.Duration, error) {
+	value, ok := c.Timeouts[operation]
+	if !ok {
+		value, ok = c.Timeouts["default"]
+	}
+	if !ok {
+		return fallback, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout for %s: %s", operation, value)
+	}
+	return timeout, nil
+}
+
+// ErrProfileNotFound is returned when the requested profile is not defined
+var ErrProfileNotFound = errors.New("profile not found")
+
+// The ConfigFile struct holds the named profiles in a configuration file.
+//
+// Top-level Configuration keys apply to every profile and are overridden by
+// the keys set within the selected profile.
+type ConfigFile struct {
+	DefaultProfile string                     `json:"default-profile"`
+	Profiles       map[string]json.RawMessage `json:"profiles"`
+}
+
 // LoadConfig parses provided JSON configuration file
-func LoadConfig(directory string) (Configuration, error) {
+//
+// When profile is empty the file's default-profile is used, if any. The
+// returned Configuration records the selected profile name.
+func LoadConfig(directory string, profile string) (Configuration, error) {
 
 	_, err := os.Stat(directory)
 	if err != nil {
@@ -151,11 +202,40 @@ func LoadConfig(directory string) (Configuration, error) {
 	}
 
 	var conf Configuration
-	err = json.Unmarshal([]byte(byteResult), &conf)
-	if err != nil {
-		fmt.Println(err)
-		return Configuration{}, fmt.Errorf("Error parsing file: %s", directory)
+	var file ConfigFile
+	if err := json.Unmarshal(byteResult, &conf); err != nil {
+		return Configuration{}, fmt.Errorf("Error parsing file: %s: %s", directory, err)
+	}
+	if err := json.Unmarshal(byteResult, &file); err != nil {
+		return Configuration{}, fmt.Errorf("Error parsing file: %s: %s", directory, err)
+	}
+
+	if profile == "" {
+		profile = file.DefaultProfile
+	}
+	if profile == "" {
+		return conf, nil
+	}
+
+	raw, ok := file.Profiles[profile]
+	if !ok {
+		return Configuration{}, fmt.Errorf("%w: %s. Available profiles: %s", ErrProfileNotFound, profile, strings.Join(file.ProfileNames(), ", "))
 	}
 
+	if err := json.Unmarshal(raw, &conf); err != nil {
+		return Configuration{}, fmt.Errorf("Error parsing profile %s in file: %s: %s", profile, directory, err)
+	}
+	conf.Profile = profile
+
 	return conf, nil
 }
+
+// ProfileNames returns the sorted names of the profiles in the file
+func (f ConfigFile) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
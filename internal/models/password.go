@@ -0,0 +1,138 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MinPasswordLength is the shortest password accepted by the OHA Server
+const MinPasswordLength = 12
+
+// Password strength labels returned by EstimatePasswordStrength
+const (
+	StrengthWeak   = "weak"
+	StrengthFair   = "fair"
+	StrengthGood   = "good"
+	StrengthStrong = "strong"
+)
+
+// usernamePattern matches the usernames accepted by the OHA Server
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// ValidateUsername validates the username against the OHA Server rules
+func ValidateUsername(username string) error {
+	if len(username) < 3 || !usernamePattern.MatchString(username) {
+		return fmt.Errorf("Invalid username. Expected at least 3 alphanumeric characters. Got: %s", username)
+	}
+	return nil
+}
+
+// PasswordPolicyViolations checks password against the OHA Server password
+// policy.
+//
+// The function returns a description of each unmet requirement, or nil when
+// the password is acceptable.
+func PasswordPolicyViolations(password string) []string {
+	var upper, lower, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			special = true
+		}
+	}
+
+	var violations []string
+	if len([]rune(password)) < MinPasswordLength {
+		violations = append(violations, fmt.Sprintf("at least %d characters", MinPasswordLength))
+	}
+	if !upper {
+		violations = append(violations, "an uppercase letter")
+	}
+	if !lower {
+		violations = append(violations, "a lowercase letter")
+	}
+	if !digit {
+		violations = append(violations, "a digit")
+	}
+	if !special {
+		violations = append(violations, "a special character")
+	}
+	return violations
+}
+
+// ValidatePassword validates the password against the OHA Server password
+// policy
+func ValidatePassword(password string) error {
+	if violations := PasswordPolicyViolations(password); len(violations) > 0 {
+		return fmt.Errorf("password does not meet the policy. It must contain %s", strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+// EstimatePasswordStrength returns a rough entropy estimate in bits for
+// password and a label describing it.
+//
+// The estimate is based on the character classes used. Characters that
+// repeat or continue a sequence such as "1234" or "abcd" add almost nothing,
+// but dictionary words are not detected so the result is an upper bound.
+func EstimatePasswordStrength(password string) (float64, string) {
+	var pool float64
+	var lower, upper, digit, special, other bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			special = true
+		}
+	}
+	for _, class := range []struct {
+		used bool
+		size float64
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {special, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0, StrengthWeak
+	}
+
+	perChar := math.Log2(pool)
+	var bits float64
+	var prev rune
+	for i, r := range password {
+		if i > 0 && (r == prev || r == prev+1 || r == prev-1) {
+			bits++
+		} else {
+			bits += perChar
+		}
+		prev = r
+	}
+
+	switch {
+	case bits < 50:
+		return bits, StrengthWeak
+	case bits < 70:
+		return bits, StrengthFair
+	case bits < 90:
+		return bits, StrengthGood
+	default:
+		return bits, StrengthStrong
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInsecurePermissions is returned when the config file may be read or
+// replaced by other users
+var ErrInsecurePermissions = errors.New("insecure config file permissions")
+
+// CheckConfigPermissions reports whether the config file at path is
+// readable or writable by the group or other users, or is owned by another
+// user, in the same way ssh treats private keys.
+//
+// A missing file is not an error. The function returns an error wrapping
+// ErrInsecurePermissions that describes the problem.
+func CheckConfigPermissions(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if uid, owner, other := fileOwner(info); other {
+		return fmt.Errorf("%w: %s is owned by uid %d, not the current user (uid %d)", ErrInsecurePermissions, path, owner, uid)
+	}
+
+	if checkModeBits && info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%w: %s is accessible by group or others (mode %04o). It should only be readable by you (mode 0600)", ErrInsecurePermissions, path, info.Mode().Perm())
+	}
+
+	return nil
+}
+
+// FixConfigPermissions restricts the config file at path to the current
+// user.
+//
+// Ownership cannot be repaired without privileges, so the function returns
+// an error when the file is owned by another user.
+func FixConfigPermissions(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if _, owner, other := fileOwner(info); other {
+		return fmt.Errorf("%s is owned by uid %d. Change the owner with chown before fixing permissions", path, owner)
+	}
+
+	return os.Chmod(path, 0600)
+}
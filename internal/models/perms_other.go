@@ -0,0 +1,15 @@
+//go:build !unix
+
+package models
+
+import "os"
+
+// checkModeBits disables the permission check on platforms such as Windows
+// where file modes do not reflect access control lists
+const checkModeBits = false
+
+// fileOwner is not supported on this platform and never reports another
+// owner
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package models
+
+import (
+	"os"
+	"syscall"
+)
+
+// checkModeBits enables the group and other permission check
+const checkModeBits = true
+
+// fileOwner returns the current uid, the uid owning info and whether they
+// differ
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	uid := os.Getuid()
+	return uid, int(stat.Uid), int(stat.Uid) != uid
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultAPIRoute is used when the server URL does not include a path
+const DefaultAPIRoute = "/api"
+
+// hostnameRegex matches a DNS hostname made of dot separated labels
+var hostnameRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\.?$`)
+
+// splitServerKeys are the keys the server URL is built from when the server
+// key is not used
+var splitServerKeys = []string{"server-url", "server-port", "server-api-route"}
+
+// originRank orders the layers a value can come from so a higher layer
+// overrides a lower one
+func originRank(origin string) int {
+	switch {
+	case strings.HasPrefix(origin, OriginFlag):
+		return 3
+	case strings.HasPrefix(origin, OriginEnv):
+		return 2
+	case strings.HasPrefix(origin, OriginFile):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// UsesServerKey reports whether the server URL comes from the server key
+// rather than the server-url, server-port and server-api-route keys.
+//
+// The server key wins unless server-url is set and one of the split keys was
+// set at a higher layer, such as SERVER_URL overriding a server in the file.
+func (c Configuration) UsesServerKey() bool {
+	if c.Server == "" {
+		return false
+	}
+	if c.ServerURL == "" {
+		return true
+	}
+
+	rank := originRank(c.Origin("server"))
+	for _, key := range splitServerKeys {
+		if originRank(c.Origin(key)) > rank {
+			return false
+		}
+	}
+	return true
+}
+
+// UnusedServerKeys returns the server keys that are set but ignored when
+// building the server URL
+func (c Configuration) UnusedServerKeys() []string {
+	if !c.UsesServerKey() {
+		if c.Server != "" {
+			return []string{"server"}
+		}
+		return nil
+	}
+
+	var unused []string
+	for _, key := range splitServerKeys {
+		if f, ok := LookupConfigField(key); ok && f.IsSet(&c) {
+			unused = append(unused, key)
+		}
+	}
+	return unused
+}
+
+// ServerBaseURL returns the base URL for the server API.
+//
+// The server key must be a full URL such as https://oha.internal:8080/api and
+// is used as decided by UsesServerKey. Otherwise the URL is built from the
+// server-url, server-port and server-api-route keys.
+// The function returns the URL and an error naming the invalid part.
+func (c Configuration) ServerBaseURL() (string, error) {
+	raw := c.Server
+	if !c.UsesServerKey() {
+		if c.ServerURL == "" {
+			return "", fmt.Errorf("no server configured. Set server or server-url")
+		}
+
+		if c.ServerPort != "" {
+			if port, err := strconv.Atoi(c.ServerPort); err != nil || port < 1 || port > 65535 {
+				return "", fmt.Errorf("invalid server-port %q: expected a number between 1 and 65535", c.ServerPort)
+			}
+		}
+
+		if c.ServerAPIRoute != "" && !strings.HasPrefix(c.ServerAPIRoute, "/") {
+			return "", fmt.Errorf("invalid server-api-route %q: expected a path starting with /", c.ServerAPIRoute)
+		}
+
+		raw = c.ServerURL
+		if !strings.Contains(raw, "://") {
+			host := strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]")
+			if c.ServerPort != "" {
+				host = net.JoinHostPort(host, c.ServerPort)
+			} else if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+			raw = "https://" + host
+		}
+		raw += c.ServerAPIRoute
+	}
+
+	return ParseServerURL(raw)
+}
+
+// ParseServerURL validates a server URL and returns it in canonical form
+// without a trailing slash.
+//
+// The function returns an error naming the scheme, host, port or path when
+// that part is invalid.
+func ParseServerURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %s", raw, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid server URL scheme %q: expected http or https", u.Scheme)
+	}
+
+	if u.User != nil {
+		return "", fmt.Errorf("invalid server URL: credentials belong in client-username and client-password")
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return "", fmt.Errorf("invalid server URL %q: missing host", raw)
+	}
+
+	if net.ParseIP(host) == nil && !hostnameRegex.MatchString(host) {
+		return "", fmt.Errorf("invalid server host %q", host)
+	}
+
+	if port := u.Port(); port != "" || strings.HasSuffix(u.Host, ":") {
+		num, err := strconv.Atoi(port)
+		if err != nil || num < 1 || num > 65535 {
+			return "", fmt.Errorf("invalid server port %q: expected a number between 1 and 65535", port)
+		}
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid server path %q: query strings and fragments are not allowed", u.Path)
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	if u.Path == "" {
+		u.Path = DefaultAPIRoute
+	}
+
+	if !strings.HasPrefix(u.Path, "/") || strings.ContainsAny(u.Path, " \t") {
+		return "", fmt.Errorf("invalid server path %q", u.Path)
+	}
+
+	u.RawPath = ""
+	return u.String(), nil
+}
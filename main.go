@@ -2,276 +2,326 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
 
 	"github.com/Scorpion-Security-Labs/ohaclient/internal/api"
 	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
 	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
 )
 
+// Exit codes for failures reported by the OHA Server
+const (
+	exitError        = 1
+	exitUnauthorized = 3
+	exitForbidden    = 4
+	exitTooLarge     = 5
+	exitRateLimited  = 6
+	exitServerError  = 7
+	exitTimeout      = 8
+	exitInterrupted  = 130
+)
+
 // OHAServerURL holds the URL for functions
 var OHAServerURL = ""
 var configFile models.Configuration
 
-func init() {
+// configPath is the configuration file the settings were loaded from
+var configPath string
 
-	var err error
+// version is set at build time with -ldflags "-X main.version=v1.0.0"
+var version = ""
 
-	// Try to load from $HOME/.oha
-	configFile, err = models.LoadConfig(fmt.Sprintf("%s/.oha", os.Getenv("HOME")))
-	if err != nil {
-		// Check if the environment variables are set
-		if os.Getenv("CLIENT_USERNAME") != "" {
-			configFile.ClientUsername = os.Getenv("CLIENT_USERNAME")
-		}
+// output buffers command results written to stdout so partial output can be
+// flushed before the process exits
+var output = bufio.NewWriter(os.Stdout)
 
-		if os.Getenv("SERVER_URL") != "" {
-			configFile.ServerURL = os.Getenv("SERVER_URL")
-		}
+// Global flags parsed before the command
+var (
+	debugFlag   bool
+	timeoutFlag string
+	profileFlag string
+	configFlag  string
 
-		if os.Getenv("SERVER_PORT") != "" {
-			configFile.ServerPort = os.Getenv("SERVER_PORT")
-		}
+	// configFlags holds configuration keys given as flags, such as --server
+	configFlags = map[string]string{}
+)
 
-		if os.Getenv("SERVER_API") != "" {
-			configFile.ServerAPIRoute = os.Getenv("SERVER_API")
-		}
+// configTried lists the locations searched for the configuration file
+var configTried []string
+
+// loadConfig finds the config file and layers it with environment variables
+// and flags into configFile.
+//
+// The function warns about config files other users can read, or refuses
+// them in strict mode, and returns any error that occurred.
+func loadConfig() error {
+	var exists bool
+	configPath, exists, configTried = models.FindConfig(configFlag)
+	managingConfig := len(os.Args) > 2 && os.Args[1] == "config" && (os.Args[2] == "init" || os.Args[2] == "set")
+	if !exists && (configFlag != "" || os.Getenv("OHA_CONFIG") != "") && !managingConfig {
+		return fmt.Errorf("config file not found: %s", configPath)
+	}
 
-		if os.Getenv("CLIENT_PASSWORD") != "" {
-			configFile.ClientPassword = os.Getenv("CLIENT_PASSWORD")
+	var err error
+	configFile, _, err = models.ResolveConfig(configPath, profileFlag, configFlags)
+	if errors.Is(err, models.ErrProfileNotFound) && managingConfig {
+		// config set creates the profile
+		err = nil
+	}
+	if err != nil && managingConfig {
+		// config init and set repair a broken file, so only the environment
+		// and flags are used. init asks before overwriting it and set
+		// reports the error when it reads the file
+		if os.Args[2] == "init" {
+			fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[!] WARNING: Ignoring the existing config file: %s", err), "yellow", "%s"))
 		}
+		configFile, _, err = models.ResolveConfig("", profileFlag, configFlags)
+	}
+	if err != nil {
+		return err
+	}
 
-		if os.Getenv("CLIENT_USERNAME") == "" || os.Getenv("SERVER_URL") == "" || os.Getenv("SERVER_PORT") == "" || os.Getenv("SERVER_API") == "" || os.Getenv("CLIENT_PASSWORD") == "" {
-			// Values do not exist at all
-			// Load the configuration settings from the config file
-			fmt.Println(config.PrintColor("[!] Unauthenticated. Please fill out Env vars or place a configuration file at ~/.oha", "red", "%s"))
-			os.Exit(1)
+	// Like ssh with private keys, warn about a config file other users can
+	// read and refuse to use it in strict mode
+	fixingPerms := len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "fix-perms"
+	if err := models.CheckConfigPermissions(configPath); err != nil && !fixingPerms {
+		if configFile.StrictPerms || !errors.Is(err, models.ErrInsecurePermissions) {
+			return err
 		}
+		fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[!] WARNING: %s", err), "yellow", "%s"))
+		fmt.Fprintln(os.Stderr, config.PrintColor("[!] Run: ohaclient config fix-perms", "yellow", "%s"))
 	}
 
-	err = models.ValidateConfig(configFile)
-	config.CheckError(err)
-	OHAServerURL = fmt.Sprintf("https://%s:%s%s", configFile.ServerURL, configFile.ServerPort, configFile.ServerAPIRoute)
+	return nil
 }
 
-func main() {
-	if len(os.Args) <= 1 {
-		printUsage()
-		os.Exit(0)
+// activeProfile returns the profile settings are read from, either the one
+// selected with --profile or OHA_PROFILE or the file's default-profile
+func activeProfile() string {
+	if configFile.Profile != "" {
+		return configFile.Profile
 	}
+	return profileFlag
+}
 
-	switch os.Args[1] {
-	case "register":
-		err := api.RegisterUser(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-	case "manage":
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
-		}
-		uid, err := models.ValidateIntInputArgs(os.Args, 2)
-		config.CheckError(err)
-
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-
-		err = api.ManageUser(OHAServerURL, jwt, uid)
-		config.CheckError(err)
-	case "search":
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
-		}
-		filepath, err := models.ValidateFileInputArgs(os.Args, 2)
-		config.CheckError(err)
-
-		query, err := models.ValidateQueryStringArgs(os.Args, 3)
-		config.CheckError(err)
-
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-
-		err = api.SearchFounds(OHAServerURL, jwt, filepath, query)
-		config.CheckError(err)
-	case "submit":
-		if len(os.Args) <= 3 {
-			printUsage()
-			os.Exit(0)
-		}
-		algo, err := models.ValidateIntInputArgs(os.Args, 2)
-		config.CheckError(err)
-
-		filepath, err := models.ValidateFileInputArgs(os.Args, 3)
-		config.CheckError(err)
-
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-
-		err = api.SubmitFounds(OHAServerURL, jwt, algo, filepath)
-		config.CheckError(err)
-	case "health":
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-
-		err = api.HealthCheck(OHAServerURL, jwt)
-		config.CheckError(err)
-	case "status":
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-
-		err = api.StatusCheck(OHAServerURL, jwt)
-		config.CheckError(err)
-	case "wordlist":
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
-		}
-
-		num, err := models.ValidateIntInputArgs(os.Args, 2)
-		config.CheckError(err)
-
-		query, err := models.ValidateQueryStringArgs(os.Args, 3)
-		config.CheckError(err)
-
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-
-		err = api.DownloadResource(OHAServerURL, jwt, "wordlist", num, query)
-		config.CheckError(err)
-	case "rules":
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
-		}
-		num, err := models.ValidateIntInputArgs(os.Args, 2)
-		config.CheckError(err)
-
-		query, err := models.ValidateQueryStringArgs(os.Args, 3)
-		config.CheckError(err)
-
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
-
-		err = api.DownloadResource(OHAServerURL, jwt, "rules", num, query)
-		config.CheckError(err)
-	case "masks":
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
+// requireServer exits with setup instructions when no server is configured
+// and otherwise sets OHAServerURL
+func requireServer() {
+	if configFile.Server == "" && configFile.ServerURL == "" {
+		// Values do not exist at all
+		fmt.Fprintln(os.Stderr, config.PrintColor("[!] Unauthenticated. No OHA Server configured. Searched for a configuration file in:", "red", "%s"))
+		for _, path := range configTried {
+			fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("    %s", path), "red", "%s"))
 		}
-		num, err := models.ValidateIntInputArgs(os.Args, 2)
-		config.CheckError(err)
+		fmt.Fprintln(os.Stderr, config.PrintColor("[!] Please fill out Env vars, pass --config PATH or run: ohaclient config init", "red", "%s"))
+		os.Exit(exitError)
+	}
 
-		query, err := models.ValidateQueryStringArgs(os.Args, 3)
-		config.CheckError(err)
+	var err error
+	OHAServerURL, err = configFile.ServerBaseURL()
+	checkError(err)
+}
 
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
+// requireCredentials resolves the password, prompting for it when needed,
+// and validates the configuration
+func requireCredentials() {
+	requireServer()
 
-		err = api.DownloadResource(OHAServerURL, jwt, "masks", num, query)
-		config.CheckError(err)
-	case "lists":
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
+	_, err := resolvePassword()
+	checkError(err)
+}
 
-		if len(os.Args) <= 2 {
-			api.ListAllPrivateLists(OHAServerURL, jwt)
-			os.Exit(0)
-		}
+// resolvePassword fills in the password from its configured source or a
+// prompt and validates the configuration.
+//
+// The function returns the password and any error that occurred.
+func resolvePassword() (string, error) {
+	if err := models.ResolvePassword(&configFile); err != nil {
+		return "", err
+	}
 
-		filename, err := models.ValidateQueryStringArgs(os.Args, 2)
-		config.CheckError(err)
+	if err := models.ValidateConfig(configFile); err != nil {
+		return "", err
+	}
+	return configFile.ClientPassword, nil
+}
 
-		err = api.ListTargetPrivateList(OHAServerURL, jwt, filename)
-		config.CheckError(err)
-	case "create":
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
+func main() {
+	parseGlobalFlags()
+	if profileFlag == "" {
+		profileFlag = os.Getenv("OHA_PROFILE")
+	}
 
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
-		}
-		filename, err := models.ValidateQueryStringArgs(os.Args, 2)
-		config.CheckError(err)
+	name := "help"
+	var args []string
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+	switch name {
+	case "-h", "--help":
+		name = "help"
+	case "--version":
+		name = "version"
+	}
 
-		newfile, err := models.ValidateQueryStringArgs(os.Args, 3)
-		config.CheckError(err)
+	c, ok := findCommand(name)
+	if !ok {
+		checkError(fmt.Errorf("%w: unknown command: %s. Run ohaclient help to list the commands", errUsage, name))
+	}
+	runCommand(c, args)
+}
 
-		err = api.CreateNewPrivateList(OHAServerURL, jwt, newfile, filename)
-		config.CheckError(err)
+// checkError prints err with guidance for known API failures and exits with
+// the matching exit code
+func checkError(err error) {
+	if err == nil {
+		return
+	}
+	output.Flush()
+
+	code := exitError
+	hint := ""
+	var apiErr *api.APIError
+	switch {
+	case errors.Is(err, api.ErrUnauthorized):
+		code = exitUnauthorized
+		hint = "Check the configured username and password or register the account first."
+	case errors.Is(err, api.ErrForbidden):
+		code = exitForbidden
+		hint = "The account lacks permission for this action. Ask an OHA administrator to grant it with the manage command."
+	case errors.Is(err, api.ErrTooLarge):
+		code = exitTooLarge
+		hint = "The request exceeded the OHA Server size limit. Split the input into smaller files."
+	case errors.Is(err, api.ErrRateLimited):
+		code = exitRateLimited
+		hint = "The OHA Server is rate limiting requests. Wait before trying again."
+	case errors.Is(err, context.Canceled):
+		code = exitInterrupted
+		hint = "Interrupted. Any partial output has been flushed."
+	case errors.Is(err, context.DeadlineExceeded):
+		code = exitTimeout
+		hint = "The operation exceeded its timeout. Raise it with --timeout or the timeouts configuration key."
+	case errors.Is(err, models.ErrInsecurePermissions):
+		hint = "The config file must be owned by and only readable by your user. Repair the mode with: ohaclient config fix-perms"
+	case errors.As(err, &apiErr):
+		code = exitServerError
+	}
 
-	case "update":
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
+	fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("error: %s\n", err), "red", "%s"))
+	if hint != "" {
+		fmt.Fprintln(os.Stderr, config.PrintColor(fmt.Sprintf("[!] %s", hint), "yellow", "%s"))
+	}
+	os.Exit(code)
+}
 
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
+// parseGlobalFlags removes global flags from os.Args so commands can keep
+// reading their positional arguments by index
+func parseGlobalFlags() {
+	args := []string{os.Args[0]}
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch {
+		case arg == "-v" || arg == "--debug":
+			debugFlag = true
+		case arg == "--timeout" && i+1 < len(os.Args):
+			i++
+			timeoutFlag = os.Args[i]
+		case strings.HasPrefix(arg, "--timeout="):
+			timeoutFlag = strings.TrimPrefix(arg, "--timeout=")
+		case arg == "--config" && i+1 < len(os.Args):
+			i++
+			configFlag = os.Args[i]
+		case strings.HasPrefix(arg, "--config="):
+			configFlag = strings.TrimPrefix(arg, "--config=")
+		case arg == "--profile" && i+1 < len(os.Args):
+			i++
+			profileFlag = os.Args[i]
+		case strings.HasPrefix(arg, "--profile="):
+			profileFlag = strings.TrimPrefix(arg, "--profile=")
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg, "=")
+			field, ok := models.IsConfigFlag(name)
+			switch {
+			case !ok:
+				args = append(args, arg)
+			case hasValue:
+				configFlags[field.Key] = value
+			case field.Bool:
+				configFlags[field.Key] = "true"
+			case i+1 < len(os.Args):
+				i++
+				configFlags[field.Key] = os.Args[i]
+			default:
+				checkError(fmt.Errorf("%s requires a value", name))
+			}
+		default:
+			args = append(args, arg)
 		}
+	}
+	os.Args = args
+}
 
-		filename, err := models.ValidateQueryStringArgs(os.Args, 2)
-		config.CheckError(err)
-
-		listname, err := models.ValidateQueryStringArgs(os.Args, 3)
-		config.CheckError(err)
-
-		err = api.UpdateTargetPrivateList(OHAServerURL, jwt, filename, listname)
-		config.CheckError(err)
-
-	case "refresh":
-		jwt, err := api.ServerAuthenticate(OHAServerURL, configFile.ClientUsername, configFile.ClientPassword)
-		config.CheckError(err)
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-		if len(os.Args) <= 2 {
-			printUsage()
-			os.Exit(0)
+// printVersion prints the client version and build platform
+func printVersion() {
+	v := version
+	if v == "" {
+		v = "dev"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
 		}
-
-		filename, err := models.ValidateQueryStringArgs(os.Args, 2)
-		config.CheckError(err)
-
-		err = api.RefreshGeneratedFile(OHAServerURL, jwt, filename)
-		config.CheckError(err)
-
-	default:
-		printUsage()
-		os.Exit(0)
 	}
+	fmt.Printf("ohaclient %s (%s %s/%s)\n", v, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 }
 
 func printUsage() {
 	fmt.Println(config.PrintColor("[+] OHA Client Configuration Settings:", "yellow", "%s"))
-	fmt.Println(config.PrintColor(fmt.Sprintf("OHA User: %s", configFile.ClientUsername), "green", "%s"))
-	fmt.Println(config.PrintColor(fmt.Sprintf("OHA Server URL: %s:%s", configFile.ServerURL, configFile.ServerPort), "green", "%s"))
-	fmt.Println(config.PrintColor(fmt.Sprintf("OHA Server API URL: %s:%s%s", configFile.ServerURL, configFile.ServerPort, configFile.ServerAPIRoute), "green", "%s"))
+	profile := configFile.Profile
+	if profile == "" {
+		profile = "(none)"
+	}
+	user := configFile.ClientUsername
+	if user == "" {
+		user = "(not configured)"
+	}
+	serverURL := OHAServerURL
+	if serverURL == "" {
+		serverURL = "(not configured)"
+	}
+	fmt.Println(config.PrintColor(fmt.Sprintf("OHA Profile: %s", profile), "green", "%s"))
+	fmt.Println(config.PrintColor(fmt.Sprintf("OHA User: %s", user), "green", "%s"))
+	fmt.Println(config.PrintColor(fmt.Sprintf("OHA Server API URL: %s", serverURL), "green", "%s"))
 	fmt.Println(config.PrintColor("[+] Available Commands:", "yellow", "%s"))
-	fmt.Println(config.PrintColor("register:", "cyan", "%s"), "Attempts user registration on the OHA Server.")
-	fmt.Println(config.PrintColor("manage:", "cyan", "%s"), "Changes user permissions for target user.")
-	fmt.Println(config.PrintColor("search:", "cyan", "%s"), "Searches the OHA Server for any matching HASH values in a file.")
-	fmt.Println(config.PrintColor("submit:", "cyan", "%s"), "Submit a file containing HASH:PLAIN values to the OHA Server.")
-	fmt.Println(config.PrintColor("health:", "cyan", "%s"), "Requests the OHA Server settings then prints them.")
-	fmt.Println(config.PrintColor("status:", "cyan", "%s"), "Check the status of downloadable files on the OHA Server.")
-	fmt.Println(config.PrintColor("wordlist:", "cyan", "%s"), "Downloads portions of the wordlist file from the OHA Server.")
-	fmt.Println(config.PrintColor("rules:", "cyan", "%s"), "Downloads portions of the rules file from the OHA Server.")
-	fmt.Println(config.PrintColor("masks:", "cyan", "%s"), "Downloads portions of the masks file from the OHA Server.")
-	fmt.Println(config.PrintColor("lists:", "cyan", "%s"), "View or downloads the available lists on the OHA Server.")
-	fmt.Println(config.PrintColor("create:", "cyan", "%s"), "Create a new private list on the OHA Server.")
-	fmt.Println(config.PrintColor("update:", "cyan", "%s"), "Updates the target list on the OHA Server.")
-	fmt.Println(config.PrintColor("refresh:", "cyan", "%s"), "Refreshes the target generated file on the OHA Server.")
+	for _, c := range commands {
+		fmt.Println(config.PrintColor(c.Name+":", "cyan", "%s"), c.Summary)
+	}
+	fmt.Println(config.PrintColor("[+] Global Flags:", "yellow", "%s"))
+	fmt.Println(config.PrintColor("--insecure:", "cyan", "%s"), "Disables TLS certificate verification. Not recommended.")
+	fmt.Println(config.PrintColor("--KEY VALUE:", "cyan", "%s"), "Overrides any configuration key for this run, e.g. --server URL or --proxy URL.")
+	fmt.Println(config.PrintColor("--config:", "cyan", "%s"), "Reads the configuration from PATH. Defaults to OHA_CONFIG, then the XDG config directory, then ~/.oha.")
+	fmt.Println(config.PrintColor("--profile:", "cyan", "%s"), "Selects a named profile from the configuration file. Defaults to OHA_PROFILE.")
+	fmt.Println(config.PrintColor("-v, --debug:", "cyan", "%s"), "Logs each request and response to stderr with secrets masked.")
+	fmt.Println(config.PrintColor("--timeout:", "cyan", "%s"), "Limits how long the command may run, e.g. 30s or 2h. 0 disables the limit.")
 	fmt.Println(config.PrintColor("[+] Example Commands:", "yellow", "%s"))
-	fmt.Println(config.PrintColor("register:", "cyan", "%s"), "ohaclient register")
-	fmt.Println(config.PrintColor("manage:", "cyan", "%s"), "ohaclient manage UID")
-	fmt.Println(config.PrintColor("search:", "cyan", "%s"), "ohaclient search FILE [QUERY-STRING]")
-	fmt.Println(config.PrintColor("submit:", "cyan", "%s"), "ohaclient found ALGO FILE")
-	fmt.Println(config.PrintColor("health:", "cyan", "%s"), "ohaclient health")
-	fmt.Println(config.PrintColor("status:", "cyan", "%s"), "ohaclient status")
-	fmt.Println(config.PrintColor("wordlist:", "cyan", "%s"), "ohaclient wordlist NUM [QUERY-STRING]")
-	fmt.Println(config.PrintColor("rules:", "cyan", "%s"), "ohaclient rules NUM [QUERY-STRING]")
-	fmt.Println(config.PrintColor("masks:", "cyan", "%s"), "ohaclient masks NUM [QUERY-STRING]")
-	fmt.Println(config.PrintColor("lists:", "cyan", "%s"), "ohaclient lists or ohaclient lists LISTNAME")
-	fmt.Println(config.PrintColor("create:", "cyan", "%s"), "ohaclient create LISTNAME FILE")
-	fmt.Println(config.PrintColor("update:", "cyan", "%s"), "ohaclient update LISTNAME FILE")
-	fmt.Println(config.PrintColor("refresh:", "cyan", "%s"), "ohaclient refresh [Masks|Rules|Wordlist]")
+	for _, c := range commands {
+		fmt.Println(config.PrintColor(c.Name+":", "cyan", "%s"), c.synopsis())
+	}
+	fmt.Println(config.PrintColor("[+] Run ohaclient COMMAND --help for the arguments, flags and examples of a command.", "yellow", "%s"))
 }
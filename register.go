@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/api"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/config"
+	"github.com/Scorpion-Security-Labs/ohaclient/internal/models"
+)
+
+// registerUser registers an account on the OHA Server.
+//
+// Configured credentials are checked against the password policy before
+// anything is sent. Missing credentials are prompted for and, after a
+// successful registration, may be saved to the configuration file. The
+// function returns any error that occurred.
+func registerUser(ctx context.Context, client *api.Client) error {
+	conf := configFile
+	var promptedUser, promptedPassword bool
+	var err error
+
+	if conf.ClientUsername == "" {
+		if !config.IsTerminal() {
+			return errors.New("no username configured. Set client-username or run register from an interactive terminal")
+		}
+		conf.ClientUsername, err = promptUsername()
+		if err != nil {
+			return err
+		}
+		promptedUser = true
+	}
+	if err := models.ValidateUsername(conf.ClientUsername); err != nil {
+		return err
+	}
+
+	if conf.ClientPassword == "" && (conf.PasswordCommand != "" || conf.PasswordFile != "") {
+		if err := models.ResolvePassword(&conf); err != nil {
+			return err
+		}
+	}
+	if conf.ClientPassword == "" {
+		if !config.IsTerminal() {
+			return errors.New("no password configured. Set client-password, password-command or password-file or run register from an interactive terminal")
+		}
+		conf.ClientPassword, err = promptNewPassword()
+		if err != nil {
+			return err
+		}
+		promptedPassword = true
+	} else {
+		if err := models.ValidatePassword(conf.ClientPassword); err != nil {
+			return err
+		}
+		printPasswordStrength(conf.ClientPassword)
+	}
+
+	message, err := client.RegisterUser(ctx, conf.ClientUsername, conf.ClientPassword)
+	if err != nil {
+		return err
+	}
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Registered %s: %s", conf.ClientUsername, message), "green", "%s"))
+
+	if (promptedUser || promptedPassword) && config.IsTerminal() {
+		return saveCredentials(conf, promptedUser, promptedPassword)
+	}
+	return nil
+}
+
+// promptUsername asks for a username until one is valid
+func promptUsername() (string, error) {
+	for {
+		username, err := config.Prompt("Username: ")
+		if err != nil {
+			return "", err
+		}
+		err = models.ValidateUsername(username)
+		if err == nil {
+			return username, nil
+		}
+		fmt.Println(config.PrintColor(fmt.Sprintf("[!] %s", err), "red", "%s"))
+	}
+}
+
+// promptNewPassword asks for a password until one meets the policy and is
+// confirmed
+func promptNewPassword() (string, error) {
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Passwords need at least %d characters with uppercase and lowercase letters, a digit and a special character", models.MinPasswordLength), "yellow", "%s"))
+	for {
+		password, err := config.PromptPassword("Password: ")
+		if err != nil {
+			return "", err
+		}
+
+		if violations := models.PasswordPolicyViolations(password); len(violations) > 0 {
+			fmt.Println(config.PrintColor(fmt.Sprintf("[!] Password must contain %s", strings.Join(violations, ", ")), "red", "%s"))
+			continue
+		}
+
+		if printPasswordStrength(password) == models.StrengthWeak && !confirm("Use this password anyway? (y/n): ") {
+			continue
+		}
+
+		confirmation, err := config.PromptPassword("Confirm password: ")
+		if err != nil {
+			return "", err
+		}
+		if confirmation != password {
+			fmt.Println(config.PrintColor("[!] Passwords do not match", "red", "%s"))
+			continue
+		}
+		return password, nil
+	}
+}
+
+// printPasswordStrength prints the estimated strength of password and
+// returns its label
+func printPasswordStrength(password string) string {
+	bits, label := models.EstimatePasswordStrength(password)
+	color := "green"
+	switch label {
+	case models.StrengthWeak:
+		color = "red"
+	case models.StrengthFair:
+		color = "yellow"
+	}
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Estimated password strength: %s (~%.0f bits)", label, bits), color, "%s"))
+	return label
+}
+
+// saveCredentials offers to write prompted credentials to the configuration
+// file, along with the server when it did not come from the file
+func saveCredentials(conf models.Configuration, saveUser bool, savePassword bool) error {
+	values := map[string]string{}
+	if saveUser && confirm(fmt.Sprintf("Save the username to %s? (y/n): ", configPath)) {
+		values["client-username"] = conf.ClientUsername
+		if conf.Server != "" && !strings.HasPrefix(conf.Origin("server"), models.OriginFile) {
+			values["server"] = conf.Server
+		}
+	}
+	if savePassword && confirm(fmt.Sprintf("Save the password to %s in plaintext? password-command is safer (y/n): ", configPath)) {
+		values["client-password"] = conf.ClientPassword
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	for _, key := range sortedKeys(values) {
+		if err := models.SetConfigValue(configPath, activeProfile(), key, values[key]); err != nil {
+			return err
+		}
+	}
+	fmt.Println(config.PrintColor(fmt.Sprintf("[+] Credentials written to %s", configPath), "green", "%s"))
+	return nil
+}